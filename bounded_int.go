@@ -4,7 +4,7 @@ import "errors"
 import "strconv"
 
 // Assert interface compliance.
-var _ FlagValue = (*BoundedInt)(nil)
+var _ PlaceholderFlagValue = (*BoundedInt)(nil)
 
 type BoundedInt struct {
 	value int
@@ -24,6 +24,8 @@ func (self BoundedInt) String() string {
 	return strconv.Itoa(int(self.value))
 }
 
+func (self *BoundedInt) Placeholder() string { return "N" }
+
 func (self *BoundedInt) ParseFromArg(arg string) error {
 	argInt64, err := strconv.ParseInt(arg, 10, strconv.IntSize)
 	if err != nil { return err }
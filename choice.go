@@ -0,0 +1,71 @@
+package badcli
+
+import "errors"
+import "strings"
+
+// Assert interface compliance.
+var _ CompletableFlagValue = (*Choice)(nil)
+var _ PlaceholderFlagValue = (*Choice)(nil)
+
+// A string value that must be one of a predefined set of choices.
+type Choice struct {
+	value string
+	choices []string
+}
+
+// Creates a choice with the given default value and allowed choices.
+// The default value doesn't need to be one of the choices (e.g., it
+// can be an empty string to indicate that no choice has been made).
+func NewChoice(value string, choices ...string) *Choice {
+	if len(choices) == 0 {
+		panic("at least one choice is required")
+	}
+	return &Choice{ value: value, choices: choices }
+}
+
+func (self *Choice) Value() string {
+	return self.value
+}
+
+func (self *Choice) String() string {
+	return self.value
+}
+
+func (self *Choice) Choices() []string {
+	return self.choices
+}
+
+// Returns the choices separated by '|', like "png|jpg|gif".
+func (self *Choice) Placeholder() string {
+	return strings.Join(self.choices, "|")
+}
+
+func (self *Choice) CompletionCandidates() CompletionCandidates {
+	return CompletionCandidates{ Words: self.choices }
+}
+
+func (self *Choice) ParseFromArg(arg string) error {
+	if arg == "" { return ErrMissingValue }
+	for _, choice := range self.choices {
+		if arg == choice {
+			self.value = arg
+			return nil
+		}
+	}
+
+	// create nice error message
+	msg := "expected " + listWithConjunction(quoteAll(self.choices), "or") + ", but got '" + arg + "' instead"
+	if closeChoice := findCloseName(arg, self.choices); closeChoice != "" {
+		msg += " (maybe you meant '" + closeChoice + "'?)"
+	}
+	return errors.New(msg)
+}
+
+// Returns the strings surrounded by single quotes.
+func quoteAll(strs []string) []string {
+	quoted := make([]string, len(strs))
+	for i, str := range strs {
+		quoted[i] = "'" + strings.ReplaceAll(str, "'", "\\'") + "'"
+	}
+	return quoted
+}
@@ -5,6 +5,7 @@ import "os"
 import "fmt"
 import "sort"
 import "image"
+import "errors"
 import "strings"
 import "unicode/utf8"
 
@@ -16,9 +17,26 @@ type CLI struct {
 	extraArgsDisallowed bool
 	extraUsageSections []string
 	helpDescription string
-	// TODO: add explicit example usages?
-	// TODO: usage pattern / scheme ? like, prog-name [--flags] path/to/file.png
-	//       (though I personally prefer usage examples right away)
+	parent *CLI // only set for subcommands
+	subcommands map[string]*CLI
+	subcommandUsage string // short description for the parent's usage
+	selectedSubcommand *CLI
+	flagConstraints []flagConstraint
+	stdout io.Writer // output for help, Print and Printf
+	stderr io.Writer // output for warnings, errors and usage failures
+	exitFn func(int) // os.Exit by default
+	lookupEnvFn func(string) (string, bool) // os.LookupEnv by default
+	envAutoBind bool
+	configEnabled bool
+	configFlagName string
+	configPaths []string
+	responseFilesEnabled bool
+	positionals []*positional
+	examples []example
+	flagGroups []string // group names, in order of first use
+	flagsInRegistrationOrder bool
+	completionFlagEnabled bool
+	completionShell string // set when --completion is used
 }
 
 // Creates a [*CLI] struct for parsing command line arguments.
@@ -31,6 +49,42 @@ func NewCLI(programName string, helpDescription string) *CLI {
 		flags: make(map[string]*flag),
 		flagShortAliases: make(map[rune]string),
 		helpDescription: helpDescription,
+		stdout: os.Stdout,
+		stderr: os.Stderr,
+		exitFn: os.Exit,
+		lookupEnvFn: os.LookupEnv,
+	}
+}
+
+// Sets the writer used for help and [CLI.Print]() / [CLI.Printf]().
+// Passing nil restores the default, which is os.Stdout.
+func (self *CLI) SetStdout(output io.Writer) {
+	if output == nil { output = os.Stdout }
+	self.stdout = output
+	for _, subcommand := range self.subcommands {
+		subcommand.SetStdout(output)
+	}
+}
+
+// Sets the writer used for warnings, fatal errors and usage failures.
+// Passing nil restores the default, which is os.Stderr.
+func (self *CLI) SetStderr(output io.Writer) {
+	if output == nil { output = os.Stderr }
+	self.stderr = output
+	for _, subcommand := range self.subcommands {
+		subcommand.SetStderr(output)
+	}
+}
+
+// Sets the function called by [CLI.ParseArguments](), [CLI.UsageFail](),
+// [CLI.Fatal]() and others to terminate the program. Passing nil restores
+// the default, which is os.Exit. If the given function returns, the
+// method that called it will also return right after.
+func (self *CLI) SetExitFunc(exitFn func(code int)) {
+	if exitFn == nil { exitFn = os.Exit }
+	self.exitFn = exitFn
+	for _, subcommand := range self.subcommands {
+		subcommand.SetExitFunc(exitFn)
 	}
 }
 
@@ -43,96 +97,341 @@ func (self *CLI) ExtraArgs() []string {
 }
 
 // Parse will read command line arguments, parse them, and exit
-// with an error code if there's any error during parsing.
+// with an error code if there's any error during parsing. If help
+// is requested, the usage is printed and the program exits too.
+// See [CLI.ParseArgs]() for a version that doesn't exit.
 func (self *CLI) ParseArguments() {
-	const SeeHelp = "Further help: %s --help\n"
+	err := self.ParseArgs(os.Args[1 : ])
+	if err == nil { return }
+
+	// help and errors refer to the deepest subcommand reached
+	chain := self.selectedChain()
+	cli := chain[len(chain) - 1]
+
+	if err == ErrHelpRequested {
+		fmt.Fprint(self.stdout, cli.helpDescription, "\n\n")
+		cli.PrintUsage(self.stdout)
+		self.exitFn(0)
+		return
+	}
+	if err == ErrJSONHelpRequested {
+		err = cli.WriteJSONDescription(self.stdout)
+		if err != nil {
+			self.FatalErr(err)
+			return
+		}
+		self.exitFn(0)
+		return
+	}
+	if err == ErrCompletionRequested {
+		err = self.WriteCompletionScript(self.stdout, self.completionShell)
+		if err != nil {
+			self.FatalErr(err)
+			return
+		}
+		self.exitFn(0)
+		return
+	}
+
+	cli.printParseError(self.stderr, err)
+	self.exitFn(2)
+}
+
+// Parses the given arguments (which must not include the program
+// name) without printing anything nor exiting. The returned error
+// will be [ErrHelpRequested] if help has been requested,
+// [ErrJSONHelpRequested] if --help=json has been used,
+// [ErrCompletionRequested] if a completion script has been requested, a
+// [*ParseError] if the arguments are invalid, or nil on success.
+//
+// Flags, positionals and extra args set by previous calls are reset
+// for the CLI and all its subcommands, so ParseArgs() can be used
+// repeatedly, but flag values are not restored to defaults,
+// except for values implementing [ResettableFlagValue] (like
+// [ValueList] and [Counter], which would otherwise accumulate).
+//
+// A "--" argument ends flag parsing: all the arguments after it
+// are treated as extra arguments, even if they start with a dash.
+func (self *CLI) ParseArgs(args []string) error {
+	if self.responseFilesEnabled {
+		var err error
+		args, err = expandResponseFiles(args)
+		if err != nil { return err }
+	}
+
+	err := self.parseArgs(args)
+	if err != nil { return err }
+	return self.finishParsing()
+}
+
+// Parses the arguments without applying fallback values nor
+// validating the final flag values, see [CLI.finishParsing]().
+func (self *CLI) parseArgs(args []string) error {
+	self.resetParsingState()
+	return self.parseCommandArgs(args)
+}
+
+// Resets the state from previous parsings for the CLI and all its
+// subcommands, recursively, so subcommands that end up not being
+// selected don't keep stale flags, positionals nor extra args.
+func (self *CLI) resetParsingState() {
+	self.extraArgs = nil
+	self.selectedSubcommand = nil
+	self.completionShell = ""
+	for _, flagPtr := range self.flags {
+		flagPtr.Source = FlagSourceDefault
+		resetValue(flagPtr.Value)
+	}
+	for _, pos := range self.positionals {
+		pos.SetByUser = false
+		resetValue(pos.Value)
+	}
+	for _, subcommand := range self.subcommands {
+		subcommand.resetParsingState()
+	}
+}
 
-	args := os.Args[1:]
+// Parses the arguments for the CLI and then for the selected
+// subcommand, if any. See [CLI.parseArgs]().
+func (self *CLI) parseCommandArgs(args []string) error {
 	index := 0
+	flagsEnded := false // set after "--", all remaining args are extra args
 	for index < len(args) {
 		arg := args[index]
-		if arg == "-h" || arg == "--help" || arg == "/?" {
-			fmt.Print(self.helpDescription, "\n\n")
-			self.PrintUsage(os.Stdout)
-			os.Exit(0)
+		if !flagsEnded && arg == "--" {
+			flagsEnded = true
+			index += 1
+			continue
 		}
 
-		if strings.HasPrefix(arg, "--") {
-			// check if flag is known
-			flagName := arg[2 : ]
-			flagPtr, found := self.flags[flagName]
-			if !found {
-				msg := "Failed to parse '%s' argument:\n\tflag name not recognized\n"
-				if len(arg) > 2 {
-					key := self.FindCloseFlagName(flagName)
-					if key != "" {
-						msg += "(Maybe you meant '--" + key + "'?)\n"
+		if !flagsEnded && (arg == "-h" || arg == "--help" || arg == "/?") {
+			return ErrHelpRequested
+		}
+		if !flagsEnded && arg == "--help=json" {
+			return ErrJSONHelpRequested
+		}
+
+		if !flagsEnded {
+			consumed, err := self.parseCompletionFlag(args, index)
+			if consumed > 0 { return err }
+		}
+
+		if !flagsEnded && strings.HasPrefix(arg, "-") && arg != "-" {
+			// split "--flag=value" and "-f=value" forms
+			flagArg, inlineValue, hasInlineValue := strings.Cut(arg, "=")
+
+			// get long flag name
+			var flagName string
+			var alias rune
+			if strings.HasPrefix(flagArg, "--") {
+				flagName = flagArg[2 : ]
+			} else if flagArg == "-" { // "-=value", no alias to decode
+				return &ParseError{ Kind: ParseErrUnknownFlag, Arg: arg }
+			} else { // short flag
+				// combined switches like "-vq" are all handled here except for
+				// the last letter, which can also take a value ("-vo out.png")
+				for runeLenAbove(flagArg, 2) {
+					var aliasLen int
+					alias, aliasLen = utf8.DecodeRuneInString(flagArg[1 : ])
+					flagName = self.findAlias(alias)
+					flagPtr, found := self.findFlag(flagName)
+					if !found {
+						return &ParseError{ Kind: ParseErrUnknownAlias, Arg: arg, Alias: alias }
 					}
+					if !isSwitch(flagPtr.Value) {
+						return &ParseError{ Kind: ParseErrMisplacedAlias, Arg: arg, FlagName: flagName, Alias: alias }
+					}
+					if flagPtr.Source == FlagSourceCLI && !isRepeatable(flagPtr.Value) {
+						return &ParseError{ Kind: ParseErrDuplicatedFlag, Arg: arg, FlagName: flagName, Alias: alias }
+					}
+					err := flagPtr.Value.ParseFromArg("")
+					if err != nil {
+						return &ParseError{
+							Kind: ParseErrInvalidValue, Arg: arg,
+							FlagName: flagName, Alias: alias, Err: err,
+						}
+					}
+					flagPtr.Source = FlagSourceCLI
+					flagArg = "-" + flagArg[1 + aliasLen : ]
+				}
+
+				alias, _ = utf8.DecodeRuneInString(flagArg[1 : ])
+				flagName = self.findAlias(alias)
+				if flagName == "" {
+					return &ParseError{ Kind: ParseErrUnknownAlias, Arg: arg, Alias: alias }
+				}
+			}
+
+			// check if flag is known, or if it's a negated switch
+			negated := false
+			flagPtr, found := self.findFlag(flagName)
+			if !found && strings.HasPrefix(flagArg, "--no-") {
+				flagPtr, found = self.findFlag(flagName[3 : ])
+				negated = found && isSwitch(flagPtr.Value)
+				found = negated
+				if negated { flagName = flagName[3 : ] }
+			}
+			if !found {
+				parseErr := &ParseError{ Kind: ParseErrUnknownFlag, Arg: arg, FlagName: flagName }
+				if flagName != "" {
+					parseErr.Suggestion = self.FindCloseFlagName(flagName)
 				}
-				fmt.Fprintf(os.Stderr, msg + "\n" + SeeHelp, arg, self.programName)
-				os.Exit(2)
+				return parseErr
 			}
 
-			// check redundant flag
-			if flagPtr.SetByUser {
-				msg := "Duplicated flag '%s'. Program flags can't be repeated.\n"
-				fmt.Fprintf(os.Stderr, msg + SeeHelp, arg, self.programName)
-				os.Exit(2)
+			// check redundant flag (aliases and long names count together)
+			if flagPtr.Source == FlagSourceCLI && !isRepeatable(flagPtr.Value) {
+				return &ParseError{ Kind: ParseErrDuplicatedFlag, Arg: arg, FlagName: flagName, Alias: alias }
 			}
 
 			// get next argument to parse flag
-			if index + 1 >= len(args) { // next value is missing
-				err := flagPtr.Value.ParseFromArg("")
-				if err != nil {
-					fmt.Fprintf(os.Stderr, "Failed to parse '%s' argument:\n", arg)
-					EachLine(err.Error(), 74, func(line string) error {
-						fmt.Fprint(os.Stderr, "\t", line, "\n")
-						return nil
-					})
-					fmt.Fprintf(os.Stderr, SeeHelp, self.programName)
-					os.Exit(2)
+			var err error
+			var consumedValue string
+			if negated { // "--no-switch" form
+				err = errors.New("negated switches can't be given a value")
+				if !hasInlineValue {
+					err = flagPtr.Value.ParseFromArg("false")
 				}
+			} else if hasInlineValue { // value given with '='
+				err = flagPtr.Value.ParseFromArg(inlineValue)
+			} else if flagPtr.HasImplicitValue && (index + 1 >= len(args) || isFlagShaped(args[index + 1])) {
+				err = flagPtr.Value.ParseFromArg(flagPtr.ImplicitValue)
+			} else if isSwitch(flagPtr.Value) || index + 1 >= len(args) { // no value
+				err = flagPtr.Value.ParseFromArg("")
 			} else { // obtain next value
 				index += 1
-				nextArg := args[index]
-				err := flagPtr.Value.ParseFromArg(nextArg)
-				if err != nil {
-					fmt.Fprintf(os.Stderr, "Failed to parse '%s %s' arguments:\n", arg, nextArg)
-					EachLine(err.Error(), 74, func(line string) error {
-						fmt.Fprint(os.Stderr, "\t", line, "\n")
-						return nil
-					})
-					fmt.Fprintf(os.Stderr, SeeHelp, self.programName)
-					os.Exit(2)
+				consumedValue = args[index]
+				err = flagPtr.Value.ParseFromArg(consumedValue)
+			}
+			if err != nil {
+				return &ParseError{
+					Kind: ParseErrInvalidValue, Arg: arg, Value: consumedValue,
+					FlagName: flagName, Alias: alias, Err: err,
 				}
 			}
 
 			// set flag as parsed
-			flagPtr.SetByUser = true
-		} else if strings.HasPrefix(arg, "-") {
-			// short flag
-			if runeLenAbove(arg, 2) {
-				msg := "Failed to parse '%s' argument:\n" +
-					"\tmulti-letter flags not allowed for single dash flags\n"
-				fmt.Fprintf(os.Stderr, msg + SeeHelp, arg, self.programName)
-				os.Exit(2)
+			flagPtr.Source = FlagSourceCLI
+		} else if !flagsEnded && len(self.subcommands) > 0 {
+			// subcommand, which parses all the remaining arguments
+			subcommand, found := self.subcommands[arg]
+			if !found {
+				return &ParseError{
+					Kind: ParseErrUnknownSubcommand, Arg: arg,
+					Suggestion: findCloseName(arg, self.subcommandNames()),
+				}
 			}
-
-			// TODO: actually I should translate to long flag and just use it
-			panic("TODO")
+			self.selectedSubcommand = subcommand
+			return subcommand.parseCommandArgs(args[index + 1 : ])
 		} else {
 			// extra argument
-			if self.extraArgsDisallowed {
-				msg := "Unexpected '%s' argument.\n"
-				fmt.Fprintf(os.Stderr, msg + SeeHelp, arg, self.programName)
-				os.Exit(2)
-			} else {
-				self.extraArgs = append(self.extraArgs, arg)
+			if self.extraArgsDisallowed && len(self.positionals) == 0 {
+				return &ParseError{ Kind: ParseErrUnexpectedArg, Arg: arg }
 			}
+			self.extraArgs = append(self.extraArgs, arg)
 		}
 
 		index += 1
 	}
+
+	return self.parsePositionals()
+}
+
+// Applies fallback values for the flags that haven't been set
+// through arguments and validates the final flag values, for the
+// CLI and all the selected subcommands. Precedence goes from
+// arguments to environment variables to config files to defaults.
+func (self *CLI) finishParsing() error {
+	chain := self.selectedChain()
+	for _, cli := range chain {
+		err := cli.applyEnvValues()
+		if err != nil { return err }
+	}
+	for _, cli := range chain {
+		err := cli.applyConfigFile()
+		if err != nil { return err }
+	}
+	return checkFlagsChain(chain)
+}
+
+// Returns the CLI followed by all the selected subcommands.
+func (self *CLI) selectedChain() []*CLI {
+	chain := []*CLI{ self }
+	for chain[len(chain) - 1].selectedSubcommand != nil {
+		chain = append(chain, chain[len(chain) - 1].selectedSubcommand)
+	}
+	return chain
+}
+
+// Calls checkFlags() for each CLI, deepest subcommand first.
+func checkFlagsChain(chain []*CLI) error {
+	for i := len(chain) - 1; i >= 0; i-- {
+		err := chain[i].checkFlags()
+		if err != nil { return err }
+	}
+	return nil
+}
+
+// Validates required flags, flag values and flag constraints
+// after parsing.
+func (self *CLI) checkFlags() error {
+	err := self.checkRequiredFlags()
+	if err != nil { return err }
+	err = self.validateFlagValues()
+	if err != nil { return err }
+	return self.checkFlagConstraints()
+}
+
+// Calls Validate() on all the flag values available to the CLI
+// that implement [ValidatedFlagValue].
+func (self *CLI) validateFlagValues() error {
+	for _, flagName := range self.availableFlagNames() {
+		flagPtr, _ := self.findFlag(flagName)
+		validatedValue, ok := flagPtr.Value.(ValidatedFlagValue)
+		if !ok { continue }
+		err := validatedValue.Validate()
+		if err != nil {
+			return &ParseError{ Kind: ParseErrInvalidValue, Arg: "--" + flagName, FlagName: flagName, Err: err }
+		}
+	}
+	return nil
+}
+
+// Returns a [*ParseError] listing all the required flags available
+// to the CLI that haven't been set, or nil if there are none.
+func (self *CLI) checkRequiredFlags() error {
+	var missing []string
+	for _, flagName := range self.availableFlagNames() {
+		flagPtr, _ := self.findFlag(flagName)
+		if flagPtr.Required && flagPtr.Source == FlagSourceDefault {
+			missing = append(missing, flagName)
+		}
+	}
+	if len(missing) == 0 { return nil }
+	return &ParseError{ Kind: ParseErrMissingRequired, MissingFlags: missing }
+}
+
+// Writes the given error as returned by [CLI.ParseArgs]() in
+// the format used by [CLI.ParseArguments](). The request sentinels
+// ([ErrHelpRequested], [ErrJSONHelpRequested] and [ErrCompletionRequested])
+// must be handled before calling this. Errors that are not [*ParseError]
+// are not expected, but are still printed as they are.
+func (self *CLI) printParseError(output io.Writer, err error) {
+	var parseErr *ParseError
+	if !errors.As(err, &parseErr) {
+		fmt.Fprintf(output, "Failed to parse arguments:\n\t%s\n", err)
+	} else {
+		context, reason := parseErr.describe()
+		fmt.Fprintf(output, "Failed to parse %s:\n", context)
+		EachLine(reason, 74, func(line string) error {
+			fmt.Fprint(output, "\t", line, "\n")
+			return nil
+		})
+		if parseErr.Suggestion != "" {
+			fmt.Fprintf(output, "(Maybe you meant '%s'?)\n", parseErr.suggestionText())
+		}
+	}
+	fmt.Fprintf(output, "Further help: %s --help\n", self.programName)
 }
 
 // Add an extra usage section displayed after the arguments.
@@ -140,6 +439,9 @@ func (self *CLI) AddUsageSection(paragraph string) {
 	self.extraUsageSections = append(self.extraUsageSections, paragraph)
 }
 
+// Registers a new flag. The long flag name must be given without
+// the "--" prefix. Aliases can be optionally given, and they work
+// exactly like using [CLI.RegisterShortAliases]() afterwards.
 func (self *CLI) RegisterFlag(longFlagName, usage string, value FlagValue, aliases ...rune) {
 	// safety checks
 	if !runeLenAbove(longFlagName, 1) {
@@ -150,9 +452,17 @@ func (self *CLI) RegisterFlag(longFlagName, usage string, value FlagValue, alias
 		panic("flag name can't start with a dash ('" + longFlagName + "')")
 	}
 
+	if strings.ContainsRune(longFlagName, '=') {
+		panic("flag name can't contain '=' ('" + longFlagName + "')")
+	}
+
 	if self.IsFlagRegistered(longFlagName) {
 		panic("flag name already registered ('" + longFlagName + "')")
 	}
+
+	if self.completionFlagEnabled && longFlagName == "completion" {
+		panic("flag name reserved by EnableCompletionFlag() ('" + longFlagName + "')")
+	}
 	
 	if value == nil {
 		panic("can't register flag with nil value")
@@ -162,7 +472,37 @@ func (self *CLI) RegisterFlag(longFlagName, usage string, value FlagValue, alias
 	self.flags[longFlagName] = &flag{
 		Value: value,
 		Usage: usage,
+		Default: describeDefault(value),
+		Index: len(self.flags),
+	}
+	if len(aliases) > 0 {
+		self.RegisterShortAliases(longFlagName, aliases...)
+	}
+}
+
+// Like [CLI.RegisterFlag](), but parsing will fail if the flag is not
+// given. Required flags are marked as such in [CLI.PrintUsage]().
+func (self *CLI) RegisterRequiredFlag(longFlagName, usage string, value FlagValue, aliases ...rune) {
+	self.RegisterFlag(longFlagName, usage, value, aliases...)
+	self.flags[longFlagName].Required = true
+}
+
+// Makes the value of the flag optional: if the flag is not followed by
+// "=value" and the next argument starts with a dash (or there are no more
+// arguments), the implicit value will be parsed instead. For example,
+// with an implicit "#fff" value, "--color --number 5" is equivalent to
+// "--color #fff --number 5". Notice that values starting with a dash
+// can still be given with the "--flag=value" syntax.
+func (self *CLI) SetImplicitValue(longFlagName, implicitValue string) {
+	if !self.IsFlagRegistered(longFlagName) {
+		panic("can't set implicit value for inexistent '" + longFlagName + "' flag")
 	}
+	flagPtr := self.flags[longFlagName]
+	if isSwitch(flagPtr.Value) {
+		panic("can't set implicit value for switch flag '" + longFlagName + "'")
+	}
+	flagPtr.HasImplicitValue = true
+	flagPtr.ImplicitValue = implicitValue
 }
 
 // Returns whether the given long flag name is registered or not.
@@ -174,7 +514,7 @@ func (self *CLI) IsFlagRegistered(longFlagName string) bool {
 
 // The returned string will be empty if the alias doesn't exist.
 func (self *CLI) AliasToFullFlag(alias rune) string {
-	return self.flagShortAliases[alias]
+	return self.findAlias(alias)
 }
 
 func (self *CLI) RegisterShortAliases(longFlagName string, aliases ...rune) {
@@ -186,6 +526,9 @@ func (self *CLI) RegisterShortAliases(longFlagName string, aliases ...rune) {
 		existingFlag, alreadyDefined := self.flagShortAliases[alias]
 		
 		// safety assertions
+		if alias == '-' || alias == '=' {
+			panic("can't register '" + string(alias) + "' as a flag alias")
+		}
 		if alreadyDefined {
 			a := string(alias)
 			if existingFlag == longFlagName {
@@ -205,7 +548,7 @@ func (self *CLI) RegisterShortAliases(longFlagName string, aliases ...rune) {
 // If [CLI.ParseArguments]() hasn't been called yet, only nil or
 // default values can be returned. See also [CLI.FlagSetByUser]().
 func (self *CLI) GetFlagValue(fullFlagName string) FlagValue {
-	flagPtr, found := self.flags[fullFlagName]
+	flagPtr, found := self.findFlag(fullFlagName)
 	if !found { return nil }
 	if flagPtr == nil { panic("internal code error") }
 	return flagPtr.Value
@@ -213,13 +556,32 @@ func (self *CLI) GetFlagValue(fullFlagName string) FlagValue {
 
 // Returns whether a flag has been explicitly set by the
 // user or not. A flag may still have a default value even
-// if it hasn't been set by the user.
+// if it hasn't been set by the user. Only command line arguments
+// count; see [CLI.FlagExplicitlySet]() to also consider values
+// from environment variables and config files.
 //
 // The name passed must be the long form of the flag name.
 func (self *CLI) FlagSetByUser(longFlagName string) bool {
-	flagPtr, found := self.flags[longFlagName]
-	if !found { return false }
-	return flagPtr.SetByUser
+	return self.FlagSource(longFlagName) == FlagSourceCLI
+}
+
+// Returns whether a flag has a value other than its default,
+// whether it comes from the command line, an environment variable
+// or a config file. See also [CLI.FlagSource]().
+//
+// The name passed must be the long form of the flag name.
+func (self *CLI) FlagExplicitlySet(longFlagName string) bool {
+	return self.FlagSource(longFlagName) != FlagSourceDefault
+}
+
+// Returns where the value of the given flag comes from. Unknown
+// flags are reported as [FlagSourceDefault].
+//
+// The name passed must be the long form of the flag name.
+func (self *CLI) FlagSource(longFlagName string) FlagSource {
+	flagPtr, found := self.findFlag(longFlagName)
+	if !found { return FlagSourceDefault }
+	return flagPtr.Source
 }
 
 // Returns whether any of the given flags have been passed
@@ -247,96 +609,67 @@ func (self *CLI) AllFlagsSetByUser(fullFlagNames ...string) bool {
 }
 
 func (self *CLI) UsageFail(fmtStr string, args ...any) {
-	fmt.Fprint(os.Stderr, "Invalid usage:\n")
+	fmt.Fprint(self.stderr, "Invalid usage:\n")
 	EachLine(fmt.Sprintf(fmtStr, args...), 74, func(line string) error {
-		fmt.Fprint(os.Stderr, "\t", line, "\n")
+		fmt.Fprint(self.stderr, "\t", line, "\n")
 		return nil
 	})
-	fmt.Fprintf(os.Stderr, "\n")
-	self.PrintUsage(os.Stderr)
-	os.Exit(2)
+	fmt.Fprintf(self.stderr, "\n")
+	self.PrintUsage(self.stderr)
+	self.exitFn(2)
 }
 
 func (self *CLI) PrintUsage(output io.Writer) {
-	fmt.Fprintf(output, "Usage of %s:\n", self.programName)
+	if len(self.positionals) == 0 {
+		fmt.Fprintf(output, "Usage of %s:\n", self.programName)
+	} else if len(self.availableFlagNames()) == 0 {
+		fmt.Fprintf(output, "Usage: %s %s\n", self.programName, self.positionalsScheme())
+	} else {
+		fmt.Fprintf(output, "Usage: %s [flags] %s\n", self.programName, self.positionalsScheme())
+	}
+	flagGroups := self.usageFlagGroups()
+	printUsageEntries(output, self.flagUsageEntries(flagGroups[0].FlagNames))
+	for _, group := range flagGroups[1 : ] {
+		fmt.Fprintf(output, "\n%s:\n", group.Name)
+		printUsageEntries(output, self.flagUsageEntries(group.FlagNames))
+	}
 
-	reverseAliases := make(map[string][]rune)
-	for aliasLetter, aliasedFlag := range self.flagShortAliases {
-		reverseAliases[aliasedFlag] = append(reverseAliases[aliasedFlag], aliasLetter)
-	}
-
-	// find flag usage description lengths
-	usageSplits := make([]split , len(self.flags))
-	flagNames   := make([]string, len(self.flags))
-	flagIndices := make([]int   , len(self.flags))
-	flagIndex := 0
-	for flagLongName, flagPtr := range self.flags {
-		flagNameLen := utf8.RuneCountInString(flagLongName) + 2
-		flagNameLen += len(reverseAliases[flagLongName])*4
-		descrLen := utf8.RuneCountInString(flagPtr.Usage)
-		usageSplits[flagIndex] = split{ leftLen: uint16(flagNameLen), rightLen: uint16(descrLen) }
-		flagNames[flagIndex]   = flagLongName
-		flagIndices[flagIndex] = flagIndex
-		flagIndex += 1
-	}
-
-	// TODO: I'm not considering the case of line breaks within Usage descriptions.
-	//       I don't know if I should check, but... I guess it's ok to ignore ftm.
-
-	tabSize := uint16(4) // approximate, we don't really know what the terminal does
-	flagVsDescrSpacing := uint16(4)
-	contentLen := 80 - tabSize - flagVsDescrSpacing
-	maxFlagLen := findBreakpointMin(usageSplits, contentLen)
-	
-	// sort flags alphabetically
-	sort.Slice(flagIndices, func(i, j int) bool {
-		return flagNames[flagIndices[i]] < flagNames[flagIndices[j]]
-	})
+	// list positionals, if any
+	if len(self.positionals) > 0 {
+		fmt.Fprint(output, "\nArguments:\n")
+		printUsageEntries(output, self.positionalUsageEntries())
+	}
 
-	// first flags iteration, print short lines
-	var strBuilder strings.Builder
-	for _, index := range flagIndices {
-		split := usageSplits[index]
-		spacesNeeded := int(maxFlagLen) - int(split.leftLen)
-		if spacesNeeded >= 0 && split.leftLen + split.rightLen <= contentLen {
-			flagName := flagNames[index]
-			strBuilder.Reset()
-			strBuilder.WriteString("\t--")
-			strBuilder.WriteString(flagName)
-			for _, letter := range reverseAliases[flagName] {
-				strBuilder.WriteString(", -")
-				strBuilder.WriteRune(letter)
-			}
-			for i := spacesNeeded + int(flagVsDescrSpacing); i > 0; i-- {
-				strBuilder.WriteByte(' ')
-			}
-			strBuilder.WriteString(self.flags[flagName].Usage)
-			strBuilder.WriteByte('\n')
-			fmt.Fprint(output, strBuilder.String())
+	// summarize flag constraints, if any
+	if len(self.flagConstraints) > 0 {
+		fmt.Fprint(output, "\nFlag constraints:\n")
+		for i, _ := range self.flagConstraints {
+			EachLine(self.flagConstraints[i].describe(false), 74, func(line string) error {
+				fmt.Fprint(output, "\t", line, "\n")
+				return nil
+			})
 		}
 	}
+
+	// list subcommands, if any
+	if len(self.subcommands) > 0 {
+		fmt.Fprint(output, "\nCommands:\n")
+		printUsageEntries(output, self.subcommandUsageEntries())
+	}
 	
-	// second flags iteration, print long lines
-	for _, index := range flagIndices {
-		split := usageSplits[index]
-		if split.leftLen > maxFlagLen || split.leftLen + split.rightLen > contentLen {
-			flagName := flagNames[index]
-			strBuilder.Reset()
-			strBuilder.WriteString("\t--")
-			strBuilder.WriteString(flagName)
-			for _, letter := range reverseAliases[flagName] {
-				strBuilder.WriteString(", -")
-				strBuilder.WriteRune(letter)
-			}
-			strBuilder.WriteByte('\n')
-			fmt.Fprint(output, strBuilder.String())
-			EachLine(self.flags[flagName].Usage, 70, func(line string) error {
+	// list examples, if any
+	if len(self.examples) > 0 {
+		fmt.Fprint(output, "\nExamples:\n")
+		for _, example := range self.examples {
+			fmt.Fprint(output, "\t", self.programName, " ", example.CommandLine, "\n")
+			if example.Description == "" { continue }
+			EachLine(example.Description, 70, func(line string) error {
 				fmt.Fprint(output, "\t     ", line, "\n")
 				return nil
 			})
 		}
 	}
-	
+
 	// write additional paragraphs, if relevant
 	for _, section := range self.extraUsageSections {
 		fmt.Fprint(output, "\n")
@@ -347,6 +680,71 @@ func (self *CLI) PrintUsage(output io.Writer) {
 	}
 }
 
+// Returns the usage entries for the given flags available to the
+// CLI (including inherited persistent flags), in the same order.
+func (self *CLI) flagUsageEntries(flagNames []string) []usageEntry {
+	reverseAliases := self.reverseAliases()
+	entries := make([]usageEntry, 0, len(flagNames))
+	var strBuilder strings.Builder
+	for _, flagName := range flagNames {
+		flagPtr, _ := self.findFlag(flagName)
+		strBuilder.Reset()
+		strBuilder.WriteString("--")
+		strBuilder.WriteString(flagName)
+		for _, letter := range reverseAliases[flagName] {
+			strBuilder.WriteString(", -")
+			strBuilder.WriteRune(letter)
+		}
+		if placeholder := placeholderOf(flagPtr.Value); placeholder != "" {
+			if flagPtr.HasImplicitValue {
+				strBuilder.WriteString("[=" + placeholder + "]")
+			} else {
+				strBuilder.WriteString(" " + placeholder)
+			}
+		}
+		descr := flagPtr.Usage
+		if showsDefault(flagPtr) { descr += " (default " + flagPtr.Default + ")" }
+		descr += self.flagUsageNotes(flagName)
+		entries = append(entries, usageEntry{ Left: strBuilder.String(), Descr: descr })
+	}
+	return entries
+}
+
+// Returns whether the default value of the flag is worth showing.
+// Switches and required flags don't show defaults.
+func showsDefault(flagPtr *flag) bool {
+	return flagPtr.Default != "" && !flagPtr.Required && !isSwitch(flagPtr.Value)
+}
+
+// Returns the notes appended to the usage description of the flag,
+// like " (repeatable) (required)", or an empty string.
+func (self *CLI) flagUsageNotes(flagName string) string {
+	flagPtr, _ := self.findFlag(flagName)
+	var notes string
+	if isRepeatable(flagPtr.Value) { notes += " (repeatable)" }
+	if flagPtr.Required { notes += " (required)" }
+	if envVarName := self.FlagEnvVar(flagName); envVarName != "" {
+		notes += " (env: " + envVarName + ")"
+	}
+	return notes
+}
+
+// Returns a map from long flag names to their short aliases, sorted.
+// Inherited persistent flags and aliases are also included.
+func (self *CLI) reverseAliases() map[string][]rune {
+	reverseAliases := make(map[string][]rune)
+	for cli := self; cli != nil; cli = cli.parent {
+		for aliasLetter, aliasedFlag := range cli.flagShortAliases {
+			if self.findAlias(aliasLetter) != aliasedFlag { continue } // shadowed
+			reverseAliases[aliasedFlag] = append(reverseAliases[aliasedFlag], aliasLetter)
+		}
+	}
+	for _, aliases := range reverseAliases {
+		sort.Slice(aliases, func(i, j int) bool { return aliases[i] < aliases[j] })
+	}
+	return reverseAliases
+}
+
 // This function will only stop iteration and forward an error if 
 // the passed function returns an error.
 //
@@ -360,15 +758,17 @@ func (self *CLI) EachFlag(fn func(string, FlagValue) error) error {
 	return nil
 }
 
-// Similar to fmt.Fprintf(os.Stderr, "Warning: ", ...).
+// Similar to fmt.Fprintf(os.Stderr, "Warning: ", ...), but using
+// the writer set with [CLI.SetStderr]() instead.
 // An extra \n is always added at the end, so don't add it yourself.
 func (self *CLI) Warn(fmtStr string, args ...any) {
-	fmt.Fprint(os.Stderr, "Warning: ")
-	fmt.Fprintf(os.Stderr, fmtStr, args...)
-	fmt.Fprint(os.Stderr, "\n")
+	fmt.Fprint(self.stderr, "Warning: ")
+	fmt.Fprintf(self.stderr, fmtStr, args...)
+	fmt.Fprint(self.stderr, "\n")
 }
 
-// Similar to fmt.Fprintf(os.Stderr, ...) and os.Exit(1).
+// Similar to fmt.Fprintf(os.Stderr, ...) and os.Exit(1), but using
+// the writer and exit function set on the CLI.
 // An extra \n is always added at the end, so don't add it yourself.
 func (self *CLI) Fatal(fmtStr string, args ...any) {
 	self.printFatalMsg(fmt.Sprintf(fmtStr, args...))
@@ -381,24 +781,26 @@ func (self *CLI) FatalErr(err error) {
 
 func (self *CLI) printFatalMsg(msg string) {
 	if len(msg) <= 67 && utf8.RuneCountInString(msg) <= 67 {
-		fmt.Fprint(os.Stderr, "Fatal error: ", msg, "\n")
+		fmt.Fprint(self.stderr, "Fatal error: ", msg, "\n")
 	} else {
-		fmt.Fprint(os.Stderr, "Fatal error:\n")
+		fmt.Fprint(self.stderr, "Fatal error:\n")
 		EachLine(msg, 74, func(line string) error {
-			fmt.Fprint(os.Stderr, "\t", line, "\n")
+			fmt.Fprint(self.stderr, "\t", line, "\n")
 			return nil
 		})
 	}
-	os.Exit(1)
+	self.exitFn(1)
 }
 
-// Equivalent to fmt.Printf. Ignores errors.
+// Equivalent to fmt.Printf, but writing to the output set with
+// [CLI.SetStdout]() (os.Stdout by default). Ignores errors.
 func (self *CLI) Printf(fmtStr string, args ...any) {
-	fmt.Printf(fmtStr, args...)
+	fmt.Fprintf(self.stdout, fmtStr, args...)
 }
 
-// Equivalent to fmt.Print. Ignores errors.
-func (self *CLI) Print(args ...any) { fmt.Print(args...) }
+// Equivalent to fmt.Print, but writing to the output set with
+// [CLI.SetStdout]() (os.Stdout by default). Ignores errors.
+func (self *CLI) Print(args ...any) { fmt.Fprint(self.stdout, args...) }
 
 // Defined for [CLI.ExportImage]().
 type ImageExportFunc = func(io.Writer, image.Image) error
@@ -407,6 +809,7 @@ func (self *CLI) ExportImage(path string, img image.Image, exportFn ImageExportF
 	file, err := os.Create(path)
    if err != nil {
 		self.Fatal("Failed to create '%s' for image export: %s", path, err)
+		return
 	}
 	
 	// proceed with export
@@ -432,20 +835,25 @@ func (self *CLI) ExportImage(path string, img image.Image, exportFn ImageExportF
 
 // May return an empty string if no close / good match exists.
 func (self *CLI) FindCloseFlagName(longFlagName string) string {
-	// Note 1: unicode normalization is probably not unnecessary in theory,
-	//         but it should virtually always be unnecessary in practice.
-	//         so I guess I'll leave that out for the moment.
-	// Note 2: this algorithm is non-deterministic if two best options
-	//         exist due to map rng iteration. Should I correct that?
-	nearestEditFlag := ""
+	return findCloseName(longFlagName, self.availableFlagNames())
+}
+
+// Returns the candidate closest to the given name, or an empty
+// string if no close / good match exists. Candidates are checked
+// in order, so the first is returned in case of ties.
+func findCloseName(name string, candidates []string) string {
+	// Note: unicode normalization is probably not unnecessary in theory,
+	//       but it should virtually always be unnecessary in practice.
+	//       so I guess I'll leave that out for the moment.
+	nearestEditName := ""
 	lowestEditDist  := 65535
-	costCutoff := len(longFlagName)/2 + 1
+	costCutoff := len(name)/2 + 1
 	if costCutoff < 7 { costCutoff = 7 }
-	for candidateFlagName, _ := range self.flags {
-		dist := EditDistance(longFlagName, candidateFlagName, costCutoff)
+	for _, candidateName := range candidates {
+		dist := EditDistance(name, candidateName, costCutoff)
 		if dist < lowestEditDist {
 			lowestEditDist = dist
-			nearestEditFlag = candidateFlagName
+			nearestEditName = candidateName
 			if dist == 0 { break }
 		}
 	}
@@ -453,14 +861,14 @@ func (self *CLI) FindCloseFlagName(longFlagName string) string {
 	if lowestEditDist < costCutoff {
 		// get longest rune length (could optimize by making EditDistance
 		// return some extra runeLen info, but not a big deal either way)
-		longLen := utf8.RuneCountInString(longFlagName)
-		nearLen := utf8.RuneCountInString(nearestEditFlag)
+		longLen := utf8.RuneCountInString(name)
+		nearLen := utf8.RuneCountInString(nearestEditName)
 		if nearLen > longLen { longLen = nearLen }
 		
 		// compute similarity rate
 		similarity := float64(longLen - lowestEditDist)/float64(longLen)
 		if similarity >= 0.5 || longLen <= 3 {
-			return nearestEditFlag
+			return nearestEditName
 		}
 	}
 	
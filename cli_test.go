@@ -0,0 +1,982 @@
+package badcli
+
+import "testing"
+import "os"
+import "errors"
+import "reflect"
+import "encoding/json"
+import "io/fs"
+import "path/filepath"
+import "strings"
+
+func newTestCLI() *CLI {
+	cli := NewCLI("test", "Test program.")
+	cli.RegisterFlag("color" , "Color in hex or rgb format.", NewColorString(0, 0, 0), 'c')
+	cli.RegisterFlag("number", "Number between 11 and 99.", NewBoundedInt(0, 11, 99), 'n')
+	cli.RegisterFlag("verbose", "Verbose output.", NewSwitch(false), 'v')
+	cli.RegisterFlag("quiet", "Quiet output.", NewSwitch(false), 'q')
+	return cli
+}
+
+func TestParseArgs(t *testing.T) {
+	tests := []struct{
+		in []string
+		color string
+		number int
+		verbose bool
+		quiet bool
+		extra []string
+	}{
+		{ // test #0
+			in: []string{"--color", "#fff", "--number", "12"},
+			color: "rgb(255, 255, 255)", number: 12,
+		},
+		{ // test #1
+			in: []string{"-c", "#fff", "-n", "12"},
+			color: "rgb(255, 255, 255)", number: 12,
+		},
+		{ // test #2
+			in: []string{"--color=#fff", "-n=12"},
+			color: "rgb(255, 255, 255)", number: 12,
+		},
+		{ // test #3
+			in: []string{"--verbose", "file.png"},
+			color: "rgb(0, 0, 0)", verbose: true, extra: []string{"file.png"},
+		},
+		{ // test #4
+			in: []string{"--no-verbose", "--quiet=true"},
+			color: "rgb(0, 0, 0)", quiet: true,
+		},
+		{ // test #5
+			in: []string{"-vqn", "33"},
+			color: "rgb(0, 0, 0)", number: 33, verbose: true, quiet: true,
+		},
+		{ // test #6
+			in: []string{"a", "--", "-v", "--color", "-"},
+			color: "rgb(0, 0, 0)", extra: []string{"a", "-v", "--color", "-"},
+		},
+	}
+
+	for i, test := range tests {
+		cli := newTestCLI()
+		err := cli.ParseArgs(test.in)
+		if err != nil {
+			t.Fatalf("test#%d, ParseArgs(%q) returned an error: %s", i, test.in, err)
+		}
+
+		color  := cli.GetFlagValue("color").(*ColorString).String()
+		number := cli.GetFlagValue("number").(*BoundedInt).Value()
+		verbose := cli.GetFlagValue("verbose").(*Switch).Value()
+		quiet   := cli.GetFlagValue("quiet").(*Switch).Value()
+		if color != test.color || number != test.number || verbose != test.verbose || quiet != test.quiet {
+			t.Fatalf(
+				"test#%d, ParseArgs(%q) => (%s, %d, %t, %t) (expected (%s, %d, %t, %t))",
+				i, test.in, color, number, verbose, quiet, test.color, test.number, test.verbose, test.quiet,
+			)
+		}
+		if !reflect.DeepEqual(cli.ExtraArgs(), test.extra) {
+			t.Fatalf("test#%d, ParseArgs(%q) extra args %q (expected %q)", i, test.in, cli.ExtraArgs(), test.extra)
+		}
+	}
+}
+
+func TestParseArgsErrors(t *testing.T) {
+	tests := []struct{
+		in []string
+		kind ParseErrorKind
+		flagName string
+	}{
+		{[]string{"--colr", "#fff"}, ParseErrUnknownFlag, "colr"},
+		{[]string{"-x"}, ParseErrUnknownAlias, ""},
+		{[]string{"-vx"}, ParseErrUnknownAlias, ""},
+		{[]string{"-nv", "12"}, ParseErrMisplacedAlias, "number"},
+		{[]string{"-c", "#fff", "--color", "#000"}, ParseErrDuplicatedFlag, "color"},
+		{[]string{"-vv"}, ParseErrDuplicatedFlag, "verbose"},
+		{[]string{"--number", "7"}, ParseErrInvalidValue, "number"},
+		{[]string{"--number="}, ParseErrInvalidValue, "number"},
+		{[]string{"--no-verbose=true"}, ParseErrInvalidValue, "verbose"},
+		{[]string{"--no-color"}, ParseErrUnknownFlag, "no-color"},
+		{[]string{"--=x"}, ParseErrUnknownFlag, ""},
+		{[]string{"-=x"}, ParseErrUnknownFlag, ""},
+	}
+
+	for i, test := range tests {
+		cli := newTestCLI()
+		err := cli.ParseArgs(test.in)
+		var parseErr *ParseError
+		if !errors.As(err, &parseErr) {
+			t.Fatalf("test#%d, ParseArgs(%q) returned '%v' (expected a *ParseError)", i, test.in, err)
+		}
+		if parseErr.Kind != test.kind || parseErr.FlagName != test.flagName {
+			t.Fatalf(
+				"test#%d, ParseArgs(%q) => (kind %d, flag '%s') (expected (kind %d, flag '%s'))",
+				i, test.in, parseErr.Kind, parseErr.FlagName, test.kind, test.flagName,
+			)
+		}
+	}
+
+	// empty flag names get a clear reason
+	err := newTestCLI().ParseArgs([]string{"-=x"})
+	if err == nil || err.Error() != "failed to parse '-=x' argument: missing flag name" {
+		t.Fatalf("unexpected error '%v'", err)
+	}
+
+	// extra args disallowed and help cases
+	cli := newTestCLI()
+	cli.DisallowExtraArgs()
+	err = cli.ParseArgs([]string{"--", "file.png"})
+	var parseErr *ParseError
+	if !errors.As(err, &parseErr) || parseErr.Kind != ParseErrUnexpectedArg {
+		t.Fatalf("expected ParseErrUnexpectedArg, got '%v'", err)
+	}
+	err = cli.ParseArgs([]string{"-v", "--help"})
+	if err != ErrHelpRequested {
+		t.Fatalf("expected ErrHelpRequested, got '%v'", err)
+	}
+}
+
+func TestOutputHooks(t *testing.T) {
+	var stdout, stderr strings.Builder
+	var exitCode int = -1
+	cli := newTestCLI()
+	cli.SetStdout(&stdout)
+	cli.SetStderr(&stderr)
+	cli.SetExitFunc(func(code int) { exitCode = code })
+
+	cli.Warn("value %d", 42)
+	if stderr.String() != "Warning: value 42\n" || exitCode != -1 {
+		t.Fatalf("unexpected Warn() output %q (exit code %d)", stderr.String(), exitCode)
+	}
+
+	stderr.Reset()
+	cli.Fatal("broken")
+	if stderr.String() != "Fatal error: broken\n" || exitCode != 1 {
+		t.Fatalf("unexpected Fatal() output %q (exit code %d)", stderr.String(), exitCode)
+	}
+
+	cli.Printf("%s-%d", "a", 1)
+	if stdout.String() != "a-1" {
+		t.Fatalf("unexpected Printf() output %q", stdout.String())
+	}
+
+	stdout.Reset()
+	defer func(args []string) { os.Args = args }(os.Args)
+	os.Args = []string{"test", "--help"}
+	cli.ParseArguments()
+	if !strings.HasPrefix(stdout.String(), "Test program.\n\nUsage of test:\n") || exitCode != 0 {
+		t.Fatalf("unexpected help output %q (exit code %d)", stdout.String(), exitCode)
+	}
+
+	stderr.Reset()
+	os.Args = []string{"test", "--colr", "#fff"}
+	cli.ParseArguments()
+	expected := "Failed to parse '--colr' argument:\n\tflag name not recognized\n" +
+		"(Maybe you meant '--color'?)\nFurther help: test --help\n"
+	if stderr.String() != expected || exitCode != 2 {
+		t.Fatalf("unexpected parse error output %q (exit code %d)", stderr.String(), exitCode)
+	}
+}
+
+func TestSubcommands(t *testing.T) {
+	cli := NewCLI("tool", "Test tool.")
+	cli.RegisterPersistentFlag("verbose", "Verbose output.", NewSwitch(false), 'v')
+	render  := cli.RegisterSubcommand("render", "Render an image.", "Renders an image.")
+	render.RegisterFlag("color", "Color in hex or rgb format.", NewColorString(0, 0, 0), 'c')
+	inspect := cli.RegisterSubcommand("inspect", "Inspect an image.", "Inspects an image.")
+	inspect.DisallowExtraArgs()
+
+	err := cli.ParseArgs([]string{"render", "-vc", "#fff", "file.png"})
+	if err != nil { t.Fatalf("unexpected error: %s", err) }
+	if cli.SelectedSubcommand() != render {
+		t.Fatalf("expected 'render' subcommand to be selected")
+	}
+	if !cli.FlagSetByUser("verbose") || !render.FlagSetByUser("verbose") || !render.FlagSetByUser("color") {
+		t.Fatalf("expected 'verbose' and 'color' flags to be set")
+	}
+	if !reflect.DeepEqual(render.ExtraArgs(), []string{"file.png"}) {
+		t.Fatalf("unexpected extra args %q", render.ExtraArgs())
+	}
+
+	err = cli.ParseArgs([]string{"-v", "inspect"})
+	if err != nil { t.Fatalf("unexpected error: %s", err) }
+	if cli.SelectedSubcommand() != inspect || !inspect.FlagSetByUser("verbose") {
+		t.Fatalf("expected 'inspect' subcommand with 'verbose' flag")
+	}
+	if render.FlagSetByUser("color") || len(render.ExtraArgs()) != 0 {
+		t.Fatalf("unselected 'render' subcommand kept state from the previous parsing")
+	}
+
+	tests := []struct{
+		in []string
+		kind ParseErrorKind
+		suggestion string
+	}{
+		{[]string{"rendr"}, ParseErrUnknownSubcommand, "render"},
+		{[]string{"inspect", "--color", "#fff"}, ParseErrUnknownFlag, ""},
+		{[]string{"inspect", "file.png"}, ParseErrUnexpectedArg, ""},
+		{[]string{"-v", "render", "--verbose"}, ParseErrDuplicatedFlag, ""},
+	}
+	for i, test := range tests {
+		err := cli.ParseArgs(test.in)
+		var parseErr *ParseError
+		if !errors.As(err, &parseErr) || parseErr.Kind != test.kind || parseErr.Suggestion != test.suggestion {
+			t.Fatalf("test#%d, ParseArgs(%q) returned '%v'", i, test.in, err)
+		}
+	}
+
+	var stdout strings.Builder
+	cli.PrintUsage(&stdout)
+	expected := "Usage of tool:\n\t--verbose, -v    Verbose output.\n\nCommands:\n" +
+		"\tinspect    Inspect an image.\n\trender     Render an image.\n"
+	if stdout.String() != expected {
+		t.Fatalf("unexpected usage:\n%s", stdout.String())
+	}
+}
+
+func TestRequiredFlags(t *testing.T) {
+	cli := newTestCLI()
+	cli.RegisterRequiredFlag("input", "Input file.", NewFilePath("", "png"), 'i')
+	cli.RegisterRequiredFlag("output", "Output file.", NewFilePath("", "png"), 'o')
+
+	err := cli.ParseArgs([]string{"-v"})
+	var parseErr *ParseError
+	if !errors.As(err, &parseErr) || parseErr.Kind != ParseErrMissingRequired {
+		t.Fatalf("expected ParseErrMissingRequired, got '%v'", err)
+	}
+	if !reflect.DeepEqual(parseErr.MissingFlags, []string{"input", "output"}) {
+		t.Fatalf("unexpected missing flags %q", parseErr.MissingFlags)
+	}
+	if err.Error() != "failed to parse arguments: missing required flags '--input', '--output'" {
+		t.Fatalf("unexpected error message '%s'", err)
+	}
+
+	err = cli.ParseArgs([]string{"-i", "in.png", "--output", "out.png"})
+	if err != nil { t.Fatalf("unexpected error: %s", err) }
+
+	var stdout strings.Builder
+	cli.PrintUsage(&stdout)
+	if !strings.Contains(stdout.String(), "--input, -i PATH     Input file. (required)\n") {
+		t.Fatalf("required flag not marked in usage:\n%s", stdout.String())
+	}
+}
+
+func TestFlagConstraints(t *testing.T) {
+	cli := newTestCLI()
+	cli.RegisterFlag("width", "Image width.", NewBoundedInt(0, 1, 4096), 'w')
+	cli.RegisterFlag("height", "Image height.", NewBoundedInt(0, 1, 4096), 'H')
+	cli.RegisterExclusiveFlags("verbose", "quiet")
+	cli.RegisterFlagDependency("width", "height")
+	cli.RegisterAtLeastOneFlag("color", "number")
+	cli.RegisterExactlyOneFlag("width", "number")
+
+	tests := []struct{
+		in []string
+		violations []string
+	}{
+		{[]string{"-n", "12"}, nil},
+		{[]string{"-c", "#fff", "-w", "10", "-H", "10", "-v"}, nil},
+		{
+			[]string{"-vq", "-n", "12"},
+			[]string{"'--verbose' and '--quiet' can't be used together"},
+		},
+		{
+			[]string{"-w", "10", "-n", "12"},
+			[]string{
+				"'--width' requires '--height'",
+				"exactly one of '--width' or '--number' is required",
+			},
+		},
+		{
+			[]string{"-H", "10"},
+			[]string{
+				"at least one of '--color' or '--number' is required",
+				"exactly one of '--width' or '--number' is required",
+			},
+		},
+	}
+
+	for i, test := range tests {
+		err := cli.ParseArgs(test.in)
+		if test.violations == nil {
+			if err != nil { t.Fatalf("test#%d, ParseArgs(%q) returned an error: %s", i, test.in, err) }
+			continue
+		}
+
+		var parseErr *ParseError
+		if !errors.As(err, &parseErr) || parseErr.Kind != ParseErrConstraint {
+			t.Fatalf("test#%d, ParseArgs(%q) returned '%v' (expected ParseErrConstraint)", i, test.in, err)
+		}
+		if !reflect.DeepEqual(parseErr.Violations, test.violations) {
+			t.Fatalf("test#%d, ParseArgs(%q) violations %q (expected %q)", i, test.in, parseErr.Violations, test.violations)
+		}
+	}
+
+	var stdout strings.Builder
+	cli.PrintUsage(&stdout)
+	if !strings.Contains(stdout.String(), "\nFlag constraints:\n\t--verbose and --quiet can't be used together\n") {
+		t.Fatalf("constraints missing from usage:\n%s", stdout.String())
+	}
+}
+
+func TestEnvConstraintPrecedence(t *testing.T) {
+	env := map[string]string{ "T_OUTPUT": "a.png" }
+	lookupEnv := func(key string) (string, bool) {
+		value, found := env[key]
+		return value, found
+	}
+	cli := NewCLI("t", "Test program.")
+	cli.RegisterFlag("output", "Output file.", NewFilePath("", "png"))
+	cli.RegisterFlag("stdout", "Write to stdout.", NewSwitch(false))
+	cli.RegisterExclusiveFlags("output", "stdout")
+	cli.SetLookupEnvFunc(lookupEnv)
+	cli.AutoBindEnv()
+
+	// the env value is overridden in effect by --stdout
+	err := cli.ParseArgs([]string{"--stdout"})
+	if err != nil { t.Fatalf("unexpected error: %s", err) }
+
+	// both set from the command line still conflict
+	err = cli.ParseArgs([]string{"--stdout", "--output", "b.png"})
+	var parseErr *ParseError
+	if !errors.As(err, &parseErr) || parseErr.Kind != ParseErrConstraint {
+		t.Fatalf("expected ParseErrConstraint, got '%v'", err)
+	}
+
+	// both set from env conflict too
+	env["T_STDOUT"] = "true"
+	err = cli.ParseArgs(nil)
+	if !errors.As(err, &parseErr) || parseErr.Kind != ParseErrConstraint {
+		t.Fatalf("expected ParseErrConstraint, got '%v'", err)
+	}
+
+	// exactly one, satisfied by the command line over env
+	cli = NewCLI("t", "Test program.")
+	cli.RegisterFlag("output", "Output file.", NewFilePath("", "png"))
+	cli.RegisterFlag("stdout", "Write to stdout.", NewSwitch(false))
+	cli.RegisterExactlyOneFlag("output", "stdout")
+	cli.SetLookupEnvFunc(lookupEnv)
+	cli.BindEnv("output", "T_OUTPUT")
+	err = cli.ParseArgs([]string{"--stdout"})
+	if err != nil { t.Fatalf("unexpected error: %s", err) }
+}
+
+func TestEnvFallback(t *testing.T) {
+	env := map[string]string{ "TEST_COLOR": "#fff", "NUM": "12", "TEST_VERBOSE": "" }
+	cli := newTestCLI()
+	cli.SetLookupEnvFunc(func(key string) (string, bool) {
+		value, found := env[key]
+		return value, found
+	})
+	cli.AutoBindEnv()
+	cli.BindEnv("number", "NUM")
+
+	err := cli.ParseArgs([]string{"-n", "33"})
+	if err != nil { t.Fatalf("unexpected error: %s", err) }
+	sources := []FlagSource{
+		cli.FlagSource("color"), cli.FlagSource("number"), cli.FlagSource("verbose"),
+	}
+	if !reflect.DeepEqual(sources, []FlagSource{FlagSourceEnv, FlagSourceCLI, FlagSourceDefault}) {
+		t.Fatalf("unexpected flag sources %v", sources)
+	}
+	if cli.FlagSetByUser("color") || !cli.FlagExplicitlySet("color") || !cli.FlagSetByUser("number") {
+		t.Fatalf("env values must count as explicitly set, but not as set by the user")
+	}
+	color  := cli.GetFlagValue("color").(*ColorString).String()
+	number := cli.GetFlagValue("number").(*BoundedInt).Value()
+	if color != "rgb(255, 255, 255)" || number != 33 {
+		t.Fatalf("unexpected flag values (%s, %d)", color, number)
+	}
+
+	env["NUM"] = "7"
+	err = cli.ParseArgs(nil)
+	var parseErr *ParseError
+	if !errors.As(err, &parseErr) || parseErr.Kind != ParseErrInvalidValue || parseErr.EnvVar != "NUM" {
+		t.Fatalf("expected ParseErrInvalidValue for 'NUM', got '%v'", err)
+	}
+	if !strings.HasPrefix(err.Error(), "failed to parse 'NUM' environment variable: ") {
+		t.Fatalf("unexpected error message '%s'", err)
+	}
+
+	var stdout strings.Builder
+	cli.PrintUsage(&stdout)
+	if !strings.Contains(stdout.String(), "Color in hex or rgb format. (default rgb(0, 0, 0)) (env: TEST_COLOR)\n") {
+		t.Fatalf("environment variable missing from usage:\n%s", stdout.String())
+	}
+}
+
+func TestConfigFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.conf")
+	config := "# test config\n" +
+		"color = \"#fff\"\n" +
+		"number = 12\n" +
+		"\n" +
+		"; switches\n" +
+		"verbose = true\n"
+	err := os.WriteFile(path, []byte(config), 0644)
+	if err != nil { t.Fatal(err) }
+
+	env := map[string]string{ "TEST_NUMBER": "33" }
+	cli := newTestCLI()
+	cli.SetLookupEnvFunc(func(key string) (string, bool) {
+		value, found := env[key]
+		return value, found
+	})
+	cli.AutoBindEnv()
+	cli.EnableConfigFile("config", filepath.Join(t.TempDir(), "missing.conf"), path)
+
+	err = cli.ParseArgs([]string{"--no-verbose"})
+	if err != nil { t.Fatalf("unexpected error: %s", err) }
+	sources := []FlagSource{
+		cli.FlagSource("color"), cli.FlagSource("number"), cli.FlagSource("verbose"),
+	}
+	if !reflect.DeepEqual(sources, []FlagSource{FlagSourceFile, FlagSourceEnv, FlagSourceCLI}) {
+		t.Fatalf("unexpected flag sources %v", sources)
+	}
+	color   := cli.GetFlagValue("color").(*ColorString).String()
+	number  := cli.GetFlagValue("number").(*BoundedInt).Value()
+	verbose := cli.GetFlagValue("verbose").(*Switch).Value()
+	if color != "rgb(255, 255, 255)" || number != 33 || verbose {
+		t.Fatalf("unexpected flag values (%s, %d, %t)", color, number, verbose)
+	}
+
+	// error cases
+	tests := []struct{
+		config string
+		kind ParseErrorKind
+		line int
+		suggestion string
+	}{
+		{"colr = #fff\n", ParseErrUnknownFlag, 1, "color"},
+		{"# ok\nquiet = maybe\n", ParseErrInvalidValue, 2, ""},
+		{"color\n", ParseErrConfigFile, 1, ""},
+		{"color = '#fff\n", ParseErrConfigFile, 1, ""},
+		{"quiet = true\nquiet = false\n", ParseErrDuplicatedFlag, 2, ""},
+		{"[render]\n", ParseErrUnknownSubcommand, 1, ""},
+	}
+	for i, test := range tests {
+		err := os.WriteFile(path, []byte(test.config), 0644)
+		if err != nil { t.Fatal(err) }
+		err = cli.ParseArgs([]string{"--config", path})
+		var parseErr *ParseError
+		if !errors.As(err, &parseErr) || parseErr.Kind != test.kind {
+			t.Fatalf("test#%d, unexpected error '%v' (expected kind %d)", i, err, test.kind)
+		}
+		if parseErr.File != path || parseErr.Line != test.line || parseErr.Suggestion != test.suggestion {
+			t.Fatalf("test#%d, unexpected error details '%v'", i, err)
+		}
+	}
+
+	// explicit missing config file
+	err = cli.ParseArgs([]string{"--config", filepath.Join(t.TempDir(), "missing.conf")})
+	var parseErr *ParseError
+	if !errors.As(err, &parseErr) || parseErr.Kind != ParseErrConfigFile || !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("expected ParseErrConfigFile for missing file, got '%v'", err)
+	}
+}
+
+func TestConfigFileSections(t *testing.T) {
+	cli := NewCLI("tool", "Test tool.")
+	cli.RegisterPersistentFlag("verbose", "Verbose output.", NewSwitch(false), 'v')
+	render := cli.RegisterSubcommand("render", "Render an image.", "Renders an image.")
+	render.RegisterFlag("color", "Color in hex or rgb format.", NewColorString(0, 0, 0), 'c')
+	inspect := cli.RegisterSubcommand("inspect", "Inspect an image.", "Inspects an image.")
+	inspect.RegisterFlag("depth", "Inspection depth.", NewBoundedInt(1, 1, 9))
+
+	config := "verbose = true\n[render]\ncolor = 1, 2, 3\n[inspect]\ndepth = 5\n"
+	err := cli.loadConfig("test.conf", strings.NewReader(config))
+	if err != nil { t.Fatalf("unexpected error: %s", err) }
+	if cli.FlagSource("verbose") != FlagSourceFile || render.FlagSetByUser("color") {
+		t.Fatalf("unexpected flag sources")
+	}
+
+	err = cli.parseArgs([]string{"render"})
+	if err != nil { t.Fatalf("unexpected error: %s", err) }
+	err = cli.loadConfig("test.conf", strings.NewReader(config))
+	if err != nil { t.Fatalf("unexpected error: %s", err) }
+	color := render.GetFlagValue("color").(*ColorString).String()
+	if color != "rgb(1, 2, 3)" || inspect.FlagSetByUser("depth") {
+		t.Fatalf("unexpected flag values")
+	}
+
+	err = cli.loadConfig("test.conf", strings.NewReader("[render]\ndepth = 3\n"))
+	var parseErr *ParseError
+	if !errors.As(err, &parseErr) || parseErr.Kind != ParseErrUnknownFlag || parseErr.Line != 2 {
+		t.Fatalf("expected ParseErrUnknownFlag at line 2, got '%v'", err)
+	}
+	expected := "failed to parse 'depth' in config file 'test.conf:2': flag name not recognized"
+	if err.Error() != expected {
+		t.Fatalf("unexpected error message '%s'", err)
+	}
+}
+
+func TestRepeatableFlags(t *testing.T) {
+	cli := newTestCLI()
+	cli.RegisterFlag("palette", "Palette colors.", NewColorStringList(), 'p')
+	cli.RegisterFlag("input", "Input files.", NewFilePathList("png").SetCountRange(1, 3), 'i')
+
+	err := cli.ParseArgs([]string{"--palette", "#fff", "-p", "#000", "-i", "a.png", "--input=b.png"})
+	if err != nil { t.Fatalf("unexpected error: %s", err) }
+	palette := cli.GetFlagValue("palette").(*ValueList[*ColorString])
+	if palette.String() != "rgb(255, 255, 255), rgb(0, 0, 0)" {
+		t.Fatalf("unexpected palette '%s'", palette)
+	}
+	inputs := cli.GetFlagValue("input").(*ValueList[*FilePath]).Values()
+	if len(inputs) != 2 || filepath.Base(inputs[1].Value()) != "b.png" {
+		t.Fatalf("unexpected inputs")
+	}
+
+	// values don't accumulate across parsings, and defaults are restored
+	cli = newTestCLI()
+	defaultColor := NewColorString(1, 2, 3)
+	cli.RegisterFlag("palette", "Palette colors.", NewColorStringList(defaultColor), 'p')
+	cli.RegisterFlag("verbose-level", "Verbosity level.", NewCounter(-1))
+	for _, args := range [][]string{{"-p", "#fff", "--verbose-level"}, {"-p", "#000", "--verbose-level"}} {
+		err = cli.ParseArgs(args)
+		if err != nil { t.Fatalf("unexpected error: %s", err) }
+	}
+	palette = cli.GetFlagValue("palette").(*ValueList[*ColorString])
+	verbosity := cli.GetFlagValue("verbose-level").(*Counter).Value()
+	if palette.String() != "rgb(0, 0, 0)" || verbosity != 1 {
+		t.Fatalf("values accumulated across parsings ('%s', %d)", palette, verbosity)
+	}
+	err = cli.ParseArgs(nil)
+	if err != nil { t.Fatalf("unexpected error: %s", err) }
+	if palette.String() != "rgb(1, 2, 3)" {
+		t.Fatalf("expected default palette to be restored, got '%s'", palette)
+	}
+
+	cli = newTestCLI()
+	cli.RegisterFlag("input", "Input files.", NewFilePathList("png").SetCountRange(1, 3), 'i')
+	err = cli.ParseArgs([]string{"-c", "#fff"})
+	var parseErr *ParseError
+	if !errors.As(err, &parseErr) || parseErr.Kind != ParseErrInvalidValue || parseErr.FlagName != "input" {
+		t.Fatalf("expected ParseErrInvalidValue for 'input', got '%v'", err)
+	}
+
+	err = cli.loadConfig("test.conf", strings.NewReader("input = a.png\ninput = b.png\ncolor = #fff\n"))
+	if err != nil { t.Fatalf("unexpected error: %s", err) }
+	if cli.GetFlagValue("input").(*ValueList[*FilePath]).Len() != 2 {
+		t.Fatalf("expected two inputs from config file")
+	}
+}
+
+func TestCounterFlags(t *testing.T) {
+	tests := []struct{
+		in []string
+		out int
+		fail bool
+	}{
+		{nil, 0, false},
+		{[]string{"-v"}, 1, false},
+		{[]string{"-vvv"}, 3, false},
+		{[]string{"-vq", "--verbose", "-v"}, 3, false},
+		{[]string{"--verbose=2"}, 2, false},
+		{[]string{"-vv", "--no-verbose"}, 0, false},
+		{[]string{"-vvvvv"}, 0, true},
+		{[]string{"--verbose=x"}, 0, true},
+	}
+
+	for i, test := range tests {
+		cli := NewCLI("test", "Test program.")
+		cli.RegisterFlag("verbose", "Verbosity level.", NewCounter(4), 'v')
+		cli.RegisterFlag("quiet", "Quiet output.", NewSwitch(false), 'q')
+		err := cli.ParseArgs(test.in)
+		if (err != nil) != test.fail {
+			t.Fatalf("test#%d, ParseArgs(%q) returned error '%v' (expected failure: %t)", i, test.in, err, test.fail)
+		}
+		count := cli.GetFlagValue("verbose").(*Counter).Value()
+		if !test.fail && count != test.out {
+			t.Fatalf("test#%d, ParseArgs(%q) => %d (expected %d)", i, test.in, count, test.out)
+		}
+	}
+
+	cli := NewCLI("test", "Test program.")
+	cli.RegisterFlag("verbose", "Verbosity level.", NewCounter(4), 'v')
+	var stdout strings.Builder
+	cli.PrintUsage(&stdout)
+	if stdout.String() != "Usage of test:\n\t--verbose, -v    Verbosity level. (repeatable)\n" {
+		t.Fatalf("unexpected usage:\n%s", stdout.String())
+	}
+}
+
+func TestImplicitValues(t *testing.T) {
+	tests := []struct{
+		in []string
+		color string
+		number int
+		extra []string
+	}{
+		{[]string{"--color", "#000"}, "rgb(0, 0, 0)", 0, nil},
+		{[]string{"--color"}, "rgb(255, 255, 255)", 0, nil},
+		{[]string{"--color", "--number", "12"}, "rgb(255, 255, 255)", 12, nil},
+		{[]string{"-vc", "-n", "12"}, "rgb(255, 255, 255)", 12, nil},
+		{[]string{"-c", "--", "file"}, "rgb(255, 255, 255)", 0, []string{"file"}},
+		{[]string{"--color=1.2.3"}, "rgb(1, 2, 3)", 0, nil},
+	}
+
+	for i, test := range tests {
+		cli := newTestCLI()
+		cli.SetImplicitValue("color", "#fff")
+		err := cli.ParseArgs(test.in)
+		if err != nil { t.Fatalf("test#%d, ParseArgs(%q) returned an error: %s", i, test.in, err) }
+		color  := cli.GetFlagValue("color").(*ColorString).String()
+		number := cli.GetFlagValue("number").(*BoundedInt).Value()
+		if color != test.color || number != test.number || !reflect.DeepEqual(cli.ExtraArgs(), test.extra) {
+			t.Fatalf(
+				"test#%d, ParseArgs(%q) => (%s, %d, %q) (expected (%s, %d, %q))",
+				i, test.in, color, number, cli.ExtraArgs(), test.color, test.number, test.extra,
+			)
+		}
+	}
+}
+
+func TestPositionals(t *testing.T) {
+	var newPositionalsCLI = func() *CLI {
+		cli := NewCLI("test", "Test program.")
+		cli.RegisterFlag("verbose", "Verbose output.", NewSwitch(false), 'v')
+		cli.RegisterPositional("input", "Input image.", NewFilePath("", "png"))
+		cli.RegisterOptionalPositional("scale", "Scale factor.", NewBoundedInt(1, 1, 8))
+		cli.RegisterVariadicPositional("rest", "Extra files.", NewFilePathList(), 0)
+		return cli
+	}
+
+	cli := newPositionalsCLI()
+	err := cli.ParseArgs([]string{"in.png", "-v", "4", "a.txt", "b.txt"})
+	if err != nil { t.Fatalf("unexpected error: %s", err) }
+	scale := cli.GetPositionalValue("scale").(*BoundedInt).Value()
+	rest  := cli.GetPositionalValue("rest").(*ValueList[*FilePath])
+	if scale != 4 || rest.Len() != 2 || !cli.PositionalSetByUser("rest") {
+		t.Fatalf("unexpected positional values (%d, %d)", scale, rest.Len())
+	}
+
+	cli = newPositionalsCLI()
+	err = cli.ParseArgs([]string{"in.png"})
+	if err != nil { t.Fatalf("unexpected error: %s", err) }
+	if !cli.PositionalSetByUser("input") || cli.PositionalSetByUser("scale") || cli.PositionalSetByUser("rest") {
+		t.Fatalf("unexpected positionals set by user")
+	}
+
+	tests := []struct{
+		in []string
+		kind ParseErrorKind
+		positional string
+	}{
+		{[]string{"-v"}, ParseErrMissingPositional, "input"},
+		{[]string{"in.jpg"}, ParseErrInvalidValue, "input"},
+		{[]string{"in.png", "9"}, ParseErrInvalidValue, "scale"},
+	}
+	for i, test := range tests {
+		err := newPositionalsCLI().ParseArgs(test.in)
+		var parseErr *ParseError
+		if !errors.As(err, &parseErr) || parseErr.Kind != test.kind || parseErr.Positional != test.positional {
+			t.Fatalf("test#%d, ParseArgs(%q) returned '%v'", i, test.in, err)
+		}
+	}
+
+	cli = NewCLI("test", "Test program.")
+	cli.RegisterPositional("input", "Input image.", NewFilePath("", "png"))
+	err = cli.ParseArgs([]string{"a.png", "b.png"})
+	var parseErr *ParseError
+	if !errors.As(err, &parseErr) || parseErr.Kind != ParseErrUnexpectedArg || parseErr.Arg != "b.png" {
+		t.Fatalf("expected ParseErrUnexpectedArg for 'b.png', got '%v'", err)
+	}
+
+	var stdout strings.Builder
+	newPositionalsCLI().PrintUsage(&stdout)
+	expected := "Usage: test [flags] <input> [<scale>] [<rest>...]\n" +
+		"\t--verbose, -v    Verbose output.\n\nArguments:\n" +
+		"\t<input>    Input image.\n\t<scale>    Scale factor.\n\t<rest>     Extra files.\n"
+	if stdout.String() != expected {
+		t.Fatalf("unexpected usage:\n%s", stdout.String())
+	}
+}
+
+func TestExamples(t *testing.T) {
+	cli := newTestCLI()
+	cli.AddExample("--color 'rgb(0, 128, 255)' -n 12", "Use a light blue color and a number.")
+	cli.AddExample("-vq", "")
+	err := cli.CheckExamples()
+	if err != nil { t.Fatalf("unexpected error: %s", err) }
+
+	var stdout strings.Builder
+	cli.PrintUsage(&stdout)
+	expected := "\nExamples:\n\ttest --color 'rgb(0, 128, 255)' -n 12\n" +
+		"\t     Use a light blue color and a number.\n\ttest -vq\n"
+	if !strings.HasSuffix(stdout.String(), expected) {
+		t.Fatalf("unexpected usage:\n%s", stdout.String())
+	}
+
+	// stale examples
+	tests := []string{"--colour '#fff'", "-n 7", "'unterminated"}
+	for i, test := range tests {
+		cli := newTestCLI()
+		cli.AddExample(test, "Stale example.")
+		err := cli.CheckExamples()
+		if err == nil { t.Fatalf("test#%d, expected error for stale example \"%s\"", i, test) }
+	}
+
+	// subcommand examples
+	cli = NewCLI("tool", "Test tool.")
+	render := cli.RegisterSubcommand("render", "Render an image.", "Renders an image.")
+	render.RegisterFlag("color", "Color in hex or rgb format.", NewColorString(0, 0, 0), 'c')
+	render.AddExample("--color 1.2.3.4.5", "Invalid color.")
+	err = cli.CheckExamples()
+	var parseErr *ParseError
+	if !errors.As(err, &parseErr) || parseErr.Kind != ParseErrInvalidValue {
+		t.Fatalf("expected ParseErrInvalidValue for subcommand example, got '%v'", err)
+	}
+
+	// persistent flags used in both root and subcommand examples
+	cli = NewCLI("tool", "Test tool.")
+	cli.RegisterPersistentFlag("verbose", "Verbose output.", NewSwitch(false), 'v')
+	render = cli.RegisterSubcommand("render", "Render an image.", "Renders an image.")
+	cli.AddExample("-v render", "Render verbosely.")
+	render.AddExample("-v", "Render verbosely.")
+	err = cli.CheckExamples()
+	if err != nil { t.Fatalf("unexpected error: %s", err) }
+	err = render.CheckExamples()
+	if err != nil { t.Fatalf("unexpected error: %s", err) }
+}
+
+func TestFlagGroups(t *testing.T) {
+	cli := NewCLI("test", "Test program.")
+	cli.RegisterFlag("output", "Output file.", NewFilePath("", "png"), 'o')
+	cli.RegisterFlag("input", "Input file.", NewFilePath("", "png"), 'i')
+	cli.RegisterFlag("verbose", "Verbose output.", NewSwitch(false), 'v')
+	cli.RegisterFlag("foreground", "Foreground color.", NewColorString(0, 0, 0))
+	cli.RegisterFlag("background", "Background color.", NewColorString(0, 0, 0))
+	cli.SetFlagGroup("Files", "output", "input")
+	cli.SetFlagGroup("Color options", "foreground", "background")
+
+	var stdout strings.Builder
+	cli.PrintUsage(&stdout)
+	expected := "Usage of test:\n\t--verbose, -v    Verbose output.\n" +
+		"\nFiles:\n\t--input, -i PATH     Input file.\n\t--output, -o PATH    Output file.\n" +
+		"\nColor options:\n\t--background COLOR    Background color. (default rgb(0, 0, 0))\n" +
+		"\t--foreground COLOR    Foreground color. (default rgb(0, 0, 0))\n"
+	if stdout.String() != expected {
+		t.Fatalf("unexpected usage:\n%s", stdout.String())
+	}
+
+	cli.KeepFlagRegistrationOrder()
+	stdout.Reset()
+	cli.PrintUsage(&stdout)
+	expected = "Usage of test:\n\t--verbose, -v    Verbose output.\n" +
+		"\nFiles:\n\t--output, -o PATH    Output file.\n\t--input, -i PATH     Input file.\n" +
+		"\nColor options:\n\t--foreground COLOR    Foreground color. (default rgb(0, 0, 0))\n" +
+		"\t--background COLOR    Background color. (default rgb(0, 0, 0))\n"
+	if stdout.String() != expected {
+		t.Fatalf("unexpected usage:\n%s", stdout.String())
+	}
+}
+
+func TestCompletion(t *testing.T) {
+	cli := newTestCLI()
+	cli.RegisterFlag("mode", "Blending mode.", NewChoice("normal", "normal", "multiply", "screen"), 'm')
+	cli.RegisterFlag("output", "Output file.", NewFilePath("", "png", "jpg"), 'o')
+	render := cli.RegisterSubcommand("render", "Render an image.", "Renders an image.")
+	render.RegisterFlag("scale", "Scaling factor.", NewBoundedInt(1, 1, 8))
+	cli.EnableCompletionFlag()
+
+	tests := []struct{ shell string; fragments []string }{
+		{ "bash", []string{
+			"--mode|-m)\n\t\t\t\tfor word in 'normal' 'multiply' 'screen'; " +
+				"do [[ \"$word\" == \"$cur\"* ]] && COMPREPLY+=(\"$word\"); done\n",
+			"--output|-o)\n\t\t\t\tCOMPREPLY+=($(compgen -d -- \"$cur\"))\n" +
+				"\t\t\t\tCOMPREPLY+=($(compgen -f -X '!*.png' -- \"$cur\"))\n" +
+				"\t\t\t\tCOMPREPLY+=($(compgen -f -X '!*.jpg' -- \"$cur\"))\n",
+			"':render') path='render' ;;",
+			"':--color'|':-c'|':--mode'|':-m'|':--number'|':-n'|':--output'|':-o') skip=1 ;;",
+			"complete -o filenames -F _test 'test'\n",
+		}},
+		{ "zsh", []string{
+			"#compdef test\n",
+			"'(--mode -m)'{--mode,-m}'[Blending mode.]:value:(normal multiply screen)'",
+			"'(--output -o)'{--output,-o}'[Output file.]:value:_files -g \"*.(png|jpg)\"'",
+			"'(--verbose -v)'{--verbose,-v}'[Verbose output.]' \\\n",
+			"'render[Render an image.]'",
+			"'render') _test_render ;;",
+		}},
+		{ "fish", []string{
+			"complete -c 'test' -f\n",
+			"-l 'mode' -s 'm' -d 'Blending mode.' -r -a 'normal multiply screen'\n",
+			"-l 'output' -s 'o' -d 'Output file.' -r -a '(__fish_complete_suffix .png) (__fish_complete_suffix .jpg)'\n",
+			"-l 'verbose' -s 'v' -d 'Verbose output.'\n",
+			"-n 'not __fish_seen_subcommand_from render' -a 'render' -d 'Render an image.'\n",
+			"-n '__fish_seen_subcommand_from render' -l 'scale' -d 'Scaling factor.' -r\n",
+		}},
+	}
+
+	defer func(args []string) { os.Args = args }(os.Args)
+	for _, test := range tests {
+		var stdout strings.Builder
+		exitCode := -1
+		cli.SetStdout(&stdout)
+		cli.SetExitFunc(func(code int) { exitCode = code })
+		os.Args = []string{"test", "--completion", test.shell}
+		cli.ParseArguments()
+		if exitCode != 0 { t.Fatalf("%s: expected exit code 0, got %d", test.shell, exitCode) }
+		for _, fragment := range test.fragments {
+			if !strings.Contains(stdout.String(), fragment) {
+				t.Fatalf("%s: missing fragment %q in script:\n%s", test.shell, fragment, stdout.String())
+			}
+		}
+	}
+
+	// invalid shell
+	err := cli.ParseArgs([]string{"--completion=tcsh"})
+	var parseErr *ParseError
+	if !errors.As(err, &parseErr) || parseErr.Kind != ParseErrInvalidValue {
+		t.Fatalf("expected ParseErrInvalidValue, got '%v'", err)
+	}
+
+	// the flag is not recognized after subcommands nor shown in usage
+	err = cli.ParseArgs([]string{"render", "--completion", "bash"})
+	if !errors.As(err, &parseErr) || parseErr.Kind != ParseErrUnknownFlag {
+		t.Fatalf("expected ParseErrUnknownFlag, got '%v'", err)
+	}
+	var usage strings.Builder
+	cli.PrintUsage(&usage)
+	if strings.Contains(usage.String(), "completion") {
+		t.Fatalf("completion flag shown in usage:\n%s", usage.String())
+	}
+
+	// bash words with spaces are quoted one by one
+	cli = NewCLI("test", "Test program.")
+	cli.RegisterFlag("font", "Font name.", NewChoice("Sans", "Sans", "Noto Serif"))
+	var script strings.Builder
+	err = cli.WriteCompletionScript(&script, "bash")
+	if err != nil { t.Fatal(err) }
+	if !strings.Contains(script.String(), "for word in 'Sans' 'Noto Serif'; do") {
+		t.Fatalf("bash words not quoted individually:\n%s", script.String())
+	}
+
+	// bash values after '=', and implicit values are only taken after '='
+	implicit := NewCLI("test", "Test program.")
+	implicit.RegisterFlag("mode", "Blending mode.", NewChoice("normal", "normal", "screen"), 'm')
+	implicit.SetImplicitValue("mode", "normal")
+	implicit.RegisterFlag("number", "A number.", NewBoundedInt(1, 1, 9), 'n')
+	implicit.RegisterSubcommand("render", "Render an image.", "Renders an image.")
+	script.Reset()
+	err = implicit.WriteCompletionScript(&script, "bash")
+	if err != nil { t.Fatal(err) }
+	for _, fragment := range []string{
+		"\telif [[ \"$prev\" == \"=\" ]]; then\n\t\tprev=\"${COMP_WORDS[COMP_CWORD-2]}\"; eq=1\n",
+		"\t\t\t':--number'|':-n') skip=1 ;;\n",
+		"--mode|-m)\n\t\t\t\tif ((eq)); then\n\t\t\t\t\tfor word in 'normal' 'screen'; ",
+	} {
+		if !strings.Contains(script.String(), fragment) {
+			t.Fatalf("missing fragment %q in script:\n%s", fragment, script.String())
+		}
+	}
+
+	// lists complete like their elements, and positional extensions are merged
+	list := NewCLI("test", "Test program.")
+	list.RegisterFlag("out", "Output files.", NewFilePathList("png", "jpg"), 'o')
+	list.RegisterPositional("input", "Input file.", NewFilePath("", "png"))
+	list.RegisterOptionalPositional("output", "Output file.", NewFilePath("", "png"))
+	script.Reset()
+	err = list.WriteCompletionScript(&script, "zsh")
+	if err != nil { t.Fatal(err) }
+	for _, fragment := range []string{
+		"{--out,-o}'[Output files.]:value:_files -g \"*.(png|jpg)\"'",
+		"'*:argument:_files -g \"*.png\"'",
+	} {
+		if !strings.Contains(script.String(), fragment) {
+			t.Fatalf("missing fragment %q in script:\n%s", fragment, script.String())
+		}
+	}
+
+	// the completion flag can't be registered once enabled
+	cli.EnableCompletionFlag()
+	defer func() {
+		if recover() == nil { t.Fatalf("expected panic when registering '--completion'") }
+	}()
+	cli.RegisterFlag("completion", "Completion.", NewSwitch(false))
+}
+
+func TestDescribe(t *testing.T) {
+	cli := newTestCLI()
+	cli.RegisterRequiredFlag("output", "Output file.", NewFilePath("", "png"), 'o')
+	cli.RegisterFlag("palette", "Palette colors.", NewColorStringList())
+	cli.SetFlagGroup("Files", "output")
+	cli.BindEnv("number", "TEST_NUMBER")
+	cli.AddUsageSection("Extra section.")
+	render := cli.RegisterSubcommand("render", "Render an image.", "Renders an image.")
+	render.RegisterPositional("input", "Input file.", NewFilePath("", "png"))
+
+	descr := cli.Describe()
+	if descr.Program != "test" || descr.Description != "Test program." || descr.Synopsis != "test [flags] <command> [args]" {
+		t.Fatalf("unexpected description header: %+v", descr)
+	}
+	if !reflect.DeepEqual(descr.Sections, []string{"Extra section."}) {
+		t.Fatalf("unexpected sections: %v", descr.Sections)
+	}
+	flagNames := make([]string, len(descr.Flags))
+	for i, flagDescr := range descr.Flags { flagNames[i] = flagDescr.Name }
+	if !reflect.DeepEqual(flagNames, []string{"color", "number", "palette", "quiet", "verbose", "output"}) {
+		t.Fatalf("unexpected flag order: %v", flagNames)
+	}
+	expected := FlagDescription{
+		Name: "number", Aliases: []string{"n"}, Usage: "Number between 11 and 99.",
+		Type: "BoundedInt", Placeholder: "N", Default: "0", EnvVar: "TEST_NUMBER",
+	}
+	if !reflect.DeepEqual(descr.Flags[1], expected) {
+		t.Fatalf("expected %+v, got %+v", expected, descr.Flags[1])
+	}
+	if descr.Flags[2].Type != "ValueList[ColorString]" || !descr.Flags[2].Repeatable {
+		t.Fatalf("unexpected palette description: %+v", descr.Flags[2])
+	}
+	if !descr.Flags[3].Switch || descr.Flags[3].Default != "false" {
+		t.Fatalf("unexpected quiet description: %+v", descr.Flags[3])
+	}
+	if !descr.Flags[5].Required || descr.Flags[5].Group != "Files" || descr.Flags[5].Type != "FilePath" {
+		t.Fatalf("unexpected output description: %+v", descr.Flags[5])
+	}
+	if len(descr.Subcommands) != 1 || descr.Subcommands[0].Usage != "Render an image." ||
+		len(descr.Subcommands[0].Positionals) != 1 || descr.Subcommands[0].Positionals[0].Name != "input" {
+		t.Fatalf("unexpected subcommands: %+v", descr.Subcommands)
+	}
+
+	// --help=json
+	defer func(args []string) { os.Args = args }(os.Args)
+	var stdout strings.Builder
+	exitCode := -1
+	cli.SetStdout(&stdout)
+	cli.SetExitFunc(func(code int) { exitCode = code })
+	os.Args = []string{"test", "render", "--help=json"}
+	cli.ParseArguments()
+	if exitCode != 0 { t.Fatalf("expected exit code 0, got %d", exitCode) }
+	var decoded CLIDescription
+	err := json.Unmarshal([]byte(stdout.String()), &decoded)
+	if err != nil { t.Fatalf("invalid json: %s", err) }
+	if !reflect.DeepEqual(decoded, render.Describe()) {
+		t.Fatalf("unexpected json description:\n%s", stdout.String())
+	}
+}
+
+type testLevel struct{ BoundedInt }
+func (self *testLevel) Placeholder() string { return "LEVEL" }
+func (self *testLevel) DefaultDescription() string { return "auto" }
+
+func TestUsagePlaceholders(t *testing.T) {
+	cli := newTestCLI()
+	cli.RegisterFlag("level", "Compression level.", &testLevel{ *NewBoundedInt(0, 0, 9) }, 'l')
+	cli.RegisterFlag("palette", "Palette colors.", NewColorStringList())
+	cli.RegisterFlag("jobs", "Parallel jobs.", NewBoundedInt(1, 1, 64), 'j')
+	cli.SetImplicitValue("jobs", "8")
+
+	var stdout strings.Builder
+	cli.PrintUsage(&stdout)
+	expected := "Usage of test:\n" +
+		"\t--color, -c COLOR    Color in hex or rgb format. (default rgb(0, 0, 0))\n" +
+		"\t--jobs, -j[=N]       Parallel jobs. (default 1)\n" +
+		"\t--level, -l LEVEL    Compression level. (default auto)\n" +
+		"\t--number, -n N       Number between 11 and 99. (default 0)\n" +
+		"\t--palette COLOR      Palette colors. (repeatable)\n" +
+		"\t--quiet, -q          Quiet output.\n" +
+		"\t--verbose, -v        Verbose output.\n"
+	if stdout.String() != expected {
+		t.Fatalf("unexpected usage:\n%s", stdout.String())
+	}
+}
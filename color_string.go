@@ -49,6 +49,8 @@ func (self *ColorString) String() string {
 	}
 }
 
+func (self *ColorString) Placeholder() string { return "COLOR" }
+
 func (self *ColorString) ParseFromArg(arg string) error {
 	// cleanup
 	arg = strings.TrimSpace(arg)
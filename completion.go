@@ -0,0 +1,490 @@
+package badcli
+
+import "io"
+import "errors"
+import "strings"
+
+// Returned by [CLI.ParseArgs]() when the hidden --completion flag is
+// found among the arguments. See [CLI.EnableCompletionFlag]().
+var ErrCompletionRequested = errors.New("completion script requested")
+
+// Shells supported by [CLI.WriteCompletionScript]().
+var completionShells = []string{"bash", "zsh", "fish"}
+
+// Enables a hidden "--completion <shell>" flag that makes
+// [CLI.ParseArguments]() print the completion script for the given
+// shell and exit. The flag is not shown in the usage, and it's only
+// recognized before any subcommand name. Typical setup for users:
+//
+//	source <(prog --completion bash)
+//	prog --completion fish | source
+func (self *CLI) EnableCompletionFlag() {
+	if self.parent != nil {
+		panic("the completion flag can only be enabled on the root CLI")
+	}
+	if _, alreadyRegistered := self.flags["completion"]; alreadyRegistered {
+		panic("can't enable completion flag, '--completion' already registered")
+	}
+	self.completionFlagEnabled = true
+}
+
+// Returns the shell requested through the hidden --completion flag
+// during the last parsing, or an empty string if none.
+func (self *CLI) RequestedCompletionShell() string {
+	return self.completionShell
+}
+
+// Parses the hidden --completion flag, if enabled and present at the
+// given index. Returns the number of arguments consumed, which is
+// zero if the argument is not the completion flag.
+func (self *CLI) parseCompletionFlag(args []string, index int) (int, error) {
+	if !self.completionFlagEnabled { return 0, nil }
+	arg := args[index]
+	flagArg, shell, hasInlineValue := strings.Cut(arg, "=")
+	if flagArg != "--completion" { return 0, nil }
+
+	consumed := 1
+	if !hasInlineValue && index + 1 < len(args) {
+		shell = args[index + 1]
+		consumed = 2
+	}
+	if !isCompletionShell(shell) {
+		var valueErr error = ErrMissingValue
+		if shell != "" {
+			valueErr = errors.New("expected " + listWithConjunction(quoteAll(completionShells), "or") +
+				", but got '" + shell + "' instead")
+		}
+		parseErr := &ParseError{ Kind: ParseErrInvalidValue, Arg: arg, FlagName: "completion", Err: valueErr }
+		if consumed == 2 { parseErr.Value = shell }
+		return consumed, parseErr
+	}
+	self.completionShell = shell
+	return consumed, ErrCompletionRequested
+}
+
+func isCompletionShell(shell string) bool {
+	for _, supported := range completionShells {
+		if shell == supported { return true }
+	}
+	return false
+}
+
+// Writes a completion script for the given shell ("bash", "zsh"
+// or "fish"). The script covers flags, aliases, subcommands and
+// the values of flags and positionals that implement
+// [CompletableFlagValue]. Switches never complete values.
+func (self *CLI) WriteCompletionScript(output io.Writer, shell string) error {
+	var script strings.Builder
+	switch shell {
+	case "bash": self.writeBashCompletion(&script)
+	case "zsh" : self.writeZshCompletion(&script)
+	case "fish": self.writeFishCompletion(&script)
+	default:
+		return errors.New("unsupported shell '" + shell + "' for completion")
+	}
+	_, err := io.WriteString(output, script.String())
+	return err
+}
+
+// Completion metadata for a single flag.
+type completionFlag struct {
+	Name string
+	Aliases []rune
+	Usage string // first line only
+	TakesValue bool
+	ImplicitValue bool // the value can only be given with '='
+	Repeatable bool
+	Candidates *CompletionCandidates // nil if no candidates
+}
+
+// Completion metadata for a CLI or subcommand.
+type completionNode struct {
+	CLI *CLI
+	Path []string // subcommand names from the root
+	Flags []completionFlag
+	Subcommands []string
+	Args *CompletionCandidates // candidates for positionals, if any
+}
+
+// Returns the completion nodes for the CLI and all its subcommands,
+// recursively, in depth first order with sorted subcommands.
+func (self *CLI) completionNodes() []completionNode {
+	var nodes []completionNode
+	var visit func(cli *CLI, path []string)
+	visit = func(cli *CLI, path []string) {
+		node := completionNode{ CLI: cli, Path: path, Subcommands: cli.subcommandNames() }
+		reverseAliases := cli.reverseAliases()
+		for _, flagName := range cli.availableFlagNames() {
+			flagPtr, _ := cli.findFlag(flagName)
+			node.Flags = append(node.Flags, completionFlag{
+				Name: flagName,
+				Aliases: reverseAliases[flagName],
+				Usage: firstLine(flagPtr.Usage),
+				TakesValue: !isSwitch(flagPtr.Value),
+				ImplicitValue: flagPtr.HasImplicitValue,
+				Repeatable: isRepeatable(flagPtr.Value),
+				Candidates: completionCandidatesOf(flagPtr.Value),
+			})
+		}
+		for _, pos := range cli.positionals {
+			candidates := completionCandidatesOf(pos.Value)
+			if candidates == nil { continue }
+			if node.Args == nil { node.Args = &CompletionCandidates{} }
+			node.Args.Words = append(node.Args.Words, candidates.Words...)
+			node.Args.Files = node.Args.Files || candidates.Files
+			for _, ext := range candidates.FileExtensions {
+				if !containsString(node.Args.FileExtensions, ext) {
+					node.Args.FileExtensions = append(node.Args.FileExtensions, ext)
+				}
+			}
+		}
+		nodes = append(nodes, node)
+		for _, name := range node.Subcommands {
+			subpath := append(append([]string{}, path...), name)
+			visit(cli.subcommands[name], subpath)
+		}
+	}
+	visit(self, nil)
+	return nodes
+}
+
+func completionCandidatesOf(value FlagValue) *CompletionCandidates {
+	completable, ok := value.(CompletableFlagValue)
+	if !ok { return nil }
+	candidates := completable.CompletionCandidates()
+	if len(candidates.Words) == 0 && !candidates.Files { return nil }
+	return &candidates
+}
+
+func containsString(list []string, str string) bool {
+	for _, elem := range list {
+		if elem == str { return true }
+	}
+	return false
+}
+
+func firstLine(str string) string {
+	line, _, _ := strings.Cut(str, "\n")
+	return line
+}
+
+// Returns the program name as a valid shell function identifier.
+func (self *CLI) completionFuncName() string {
+	return "_" + strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return '_'
+	}, self.programName)
+}
+
+// Quotes a string for POSIX shells and fish.
+func shellQuote(str string) string {
+	return "'" + strings.ReplaceAll(str, "'", "'\\''") + "'"
+}
+
+// Returns the flag and its aliases in their command line form.
+func (self *completionFlag) forms() []string {
+	forms := []string{"--" + self.Name}
+	for _, alias := range self.Aliases {
+		forms = append(forms, "-" + string(alias))
+	}
+	return forms
+}
+
+// --- bash ---
+
+func (self *CLI) writeBashCompletion(script *strings.Builder) {
+	funcName := self.completionFuncName()
+	nodes := self.completionNodes()
+
+	script.WriteString("# bash completion for " + self.programName + "\n")
+	script.WriteString(funcName + "() {\n")
+	script.WriteString("\tlocal cur=\"${COMP_WORDS[COMP_CWORD]}\"\n")
+	script.WriteString("\tlocal prev=\"${COMP_WORDS[COMP_CWORD-1]}\"\n")
+	script.WriteString("\tlocal IFS=$'\\n' path=\"\" word skip=0 eq=0 i\n")
+
+	// "--flag=value" is split into "--flag", "=" and "value", so the
+	// flag is found before the '=' when completing the value
+	script.WriteString("\tif [[ \"$cur\" == \"=\" ]]; then\n")
+	script.WriteString("\t\tcur=\"\"; eq=1\n")
+	script.WriteString("\telif [[ \"$prev\" == \"=\" ]]; then\n")
+	script.WriteString("\t\tprev=\"${COMP_WORDS[COMP_CWORD-2]}\"; eq=1\n")
+	script.WriteString("\tfi\n")
+	if len(nodes) > 1 {
+		// find the subcommand path, skipping the values of flags
+		// (flags with implicit values only take them after '=')
+		script.WriteString("\tfor ((i = 1; i < COMP_CWORD; i++)); do\n")
+		script.WriteString("\t\tword=\"${COMP_WORDS[i]}\"\n")
+		script.WriteString("\t\tif ((skip)); then\n")
+		script.WriteString("\t\t\t[[ \"$word\" == \"=\" ]] || skip=0\n")
+		script.WriteString("\t\t\tcontinue\n")
+		script.WriteString("\t\tfi\n")
+		script.WriteString("\t\tcase \"$path:$word\" in\n")
+		script.WriteString("\t\t\t*':=') skip=1 ;;\n")
+		for _, node := range nodes {
+			if len(node.Subcommands) == 0 { continue }
+			path := strings.Join(node.Path, " ")
+			for _, name := range node.Subcommands {
+				subpath := strings.Join(append(append([]string{}, node.Path...), name), " ")
+				script.WriteString("\t\t\t" + shellQuote(path + ":" + name) + ") path=" + shellQuote(subpath) + " ;;\n")
+			}
+			var valueForms []string
+			for _, flag := range node.Flags {
+				if !flag.TakesValue || flag.ImplicitValue { continue }
+				for _, form := range flag.forms() {
+					valueForms = append(valueForms, shellQuote(path + ":" + form))
+				}
+			}
+			if len(valueForms) > 0 {
+				script.WriteString("\t\t\t" + strings.Join(valueForms, "|") + ") skip=1 ;;\n")
+			}
+		}
+		script.WriteString("\t\tesac\n")
+		script.WriteString("\tdone\n")
+	}
+	script.WriteString("\tCOMPREPLY=()\n")
+	script.WriteString("\tcase \"$path\" in\n")
+	for _, node := range nodes {
+		script.WriteString("\t" + shellQuote(strings.Join(node.Path, " ")) + ")\n")
+
+		// flag values
+		var valueCases strings.Builder
+		for _, flag := range node.Flags {
+			if !flag.TakesValue { continue }
+			indent := "\t\t\t\t"
+			valueCases.WriteString("\t\t\t" + strings.Join(flag.forms(), "|") + ")\n")
+			if flag.ImplicitValue {
+				valueCases.WriteString(indent + "if ((eq)); then\n")
+				indent += "\t"
+			}
+			if flag.Candidates != nil {
+				valueCases.WriteString(bashCompletions(flag.Candidates, indent))
+			}
+			if flag.ImplicitValue {
+				valueCases.WriteString(indent + "return\n\t\t\t\tfi ;;\n")
+			} else {
+				valueCases.WriteString(indent + "return ;;\n")
+			}
+		}
+		if valueCases.Len() > 0 {
+			script.WriteString("\t\tcase \"$prev\" in\n")
+			script.WriteString(valueCases.String())
+			script.WriteString("\t\tesac\n")
+		}
+
+		// flags, subcommands and positionals
+		var words []string
+		for _, flag := range node.Flags {
+			words = append(words, flag.forms()...)
+		}
+		script.WriteString("\t\tif [[ \"$cur\" == -* ]]; then\n")
+		script.WriteString(bashCompletions(&CompletionCandidates{ Words: words }, "\t\t\t"))
+		if len(node.Subcommands) > 0 {
+			script.WriteString("\t\telse\n")
+			script.WriteString(bashCompletions(&CompletionCandidates{ Words: node.Subcommands }, "\t\t\t"))
+		} else if node.Args != nil {
+			script.WriteString("\t\telse\n")
+			script.WriteString(bashCompletions(node.Args, "\t\t\t"))
+		}
+		script.WriteString("\t\tfi\n")
+		script.WriteString("\t\t;;\n")
+	}
+	script.WriteString("\tesac\n")
+	script.WriteString("}\n")
+	script.WriteString("complete -o filenames -F " + funcName + " " + shellQuote(self.programName) + "\n")
+}
+
+// Returns bash statements adding the given candidates to COMPREPLY,
+// with one statement per line and the given indentation. Words are
+// quoted one by one, so they can contain spaces, and generated file
+// names are only split at line breaks (IFS is set by the caller).
+func bashCompletions(candidates *CompletionCandidates, indent string) string {
+	var statements []string
+	if len(candidates.Words) > 0 {
+		quoted := make([]string, len(candidates.Words))
+		for i, word := range candidates.Words {
+			quoted[i] = shellQuote(word)
+		}
+		statements = append(statements, "for word in " + strings.Join(quoted, " ") +
+			"; do [[ \"$word\" == \"$cur\"* ]] && COMPREPLY+=(\"$word\"); done")
+	}
+	if candidates.Files {
+		if len(candidates.FileExtensions) == 0 {
+			statements = append(statements, "COMPREPLY+=($(compgen -f -- \"$cur\"))")
+		} else {
+			statements = append(statements, "COMPREPLY+=($(compgen -d -- \"$cur\"))")
+			for _, ext := range candidates.FileExtensions {
+				statements = append(statements, "COMPREPLY+=($(compgen -f -X " + shellQuote("!*." + ext) + " -- \"$cur\"))")
+			}
+		}
+	}
+	if len(statements) == 0 { statements = append(statements, ":") } // no-op
+	return indent + strings.Join(statements, "\n" + indent) + "\n"
+}
+
+// --- zsh ---
+
+func (self *CLI) writeZshCompletion(script *strings.Builder) {
+	nodes := self.completionNodes()
+	script.WriteString("#compdef " + self.programName + "\n")
+	script.WriteString("# zsh completion for " + self.programName + "\n")
+	for i, node := range nodes {
+		if i > 0 { script.WriteString("\n") }
+		funcName := node.CLI.completionFuncName()
+		script.WriteString(funcName + "() {\n")
+		script.WriteString("\tlocal state\n")
+		script.WriteString("\t_arguments -s")
+		for _, flag := range node.Flags {
+			script.WriteString(" \\\n\t\t" + zshFlagSpec(&flag))
+		}
+		if len(node.Subcommands) > 0 {
+			script.WriteString(" \\\n\t\t'1: :->command' \\\n\t\t'*:: :->args'")
+		} else if node.Args != nil {
+			script.WriteString(" \\\n\t\t" + shellQuote("*:argument:" + zshAction(node.Args)))
+		}
+		script.WriteString("\n")
+		if len(node.Subcommands) > 0 {
+			script.WriteString("\tcase $state in\n")
+			script.WriteString("\t\tcommand)\n")
+			script.WriteString("\t\t\t_values 'command'")
+			for _, name := range node.Subcommands {
+				usage := firstLine(node.CLI.subcommands[name].subcommandUsage)
+				script.WriteString(" \\\n\t\t\t\t" + shellQuote(name + "[" + zshEscape(usage) + "]"))
+			}
+			script.WriteString(" ;;\n")
+			script.WriteString("\t\targs)\n")
+			script.WriteString("\t\t\tcase $words[1] in\n")
+			for _, name := range node.Subcommands {
+				subFuncName := node.CLI.subcommands[name].completionFuncName()
+				script.WriteString("\t\t\t\t" + shellQuote(name) + ") " + subFuncName + " ;;\n")
+			}
+			script.WriteString("\t\t\tesac ;;\n")
+			script.WriteString("\tesac\n")
+		}
+		script.WriteString("}\n")
+	}
+	script.WriteString("\n" + self.completionFuncName() + " \"$@\"\n")
+}
+
+// Returns the _arguments spec for the given flag.
+func zshFlagSpec(flag *completionFlag) string {
+	forms := flag.forms()
+	var spec string
+	if flag.Repeatable {
+		spec = "'*'"
+	} else if len(forms) > 1 {
+		spec = shellQuote("(" + strings.Join(forms, " ") + ")")
+	}
+	if len(forms) > 1 {
+		spec += "{" + strings.Join(forms, ",") + "}"
+	} else {
+		spec += forms[0]
+	}
+
+	tail := "[" + zshEscape(flag.Usage) + "]"
+	if flag.TakesValue {
+		action := " "
+		if flag.Candidates != nil { action = zshAction(flag.Candidates) }
+		tail += ":value:" + action
+	}
+	return spec + shellQuote(tail)
+}
+
+// Returns the _arguments action for the given candidates.
+func zshAction(candidates *CompletionCandidates) string {
+	if candidates.Files {
+		switch len(candidates.FileExtensions) {
+		case 0: return "_files"
+		case 1: return "_files -g \"*." + candidates.FileExtensions[0] + "\""
+		default:
+			return "_files -g \"*.(" + strings.Join(candidates.FileExtensions, "|") + ")\""
+		}
+	}
+	words := make([]string, len(candidates.Words))
+	for i, word := range candidates.Words {
+		words[i] = strings.ReplaceAll(zshEscape(word), " ", "\\ ")
+	}
+	return "(" + strings.Join(words, " ") + ")"
+}
+
+// Escapes the characters with special meaning in _arguments specs.
+func zshEscape(str string) string {
+	return strings.NewReplacer("\\", "\\\\", "[", "\\[", "]", "\\]").Replace(str)
+}
+
+// --- fish ---
+
+func (self *CLI) writeFishCompletion(script *strings.Builder) {
+	nodes := self.completionNodes()
+	prefix := "complete -c " + shellQuote(self.programName)
+	script.WriteString("# fish completion for " + self.programName + "\n")
+	script.WriteString(prefix + " -f\n")
+	for _, node := range nodes {
+		condition := fishCondition(node.Path)
+		if len(node.Subcommands) > 0 {
+			subcondition := "not __fish_seen_subcommand_from " + strings.Join(node.Subcommands, " ")
+			if condition != "" { subcondition = condition + "; and " + subcondition }
+			for _, name := range node.Subcommands {
+				usage := firstLine(node.CLI.subcommands[name].subcommandUsage)
+				script.WriteString(prefix + " -n " + shellQuote(subcondition) + " -a " + shellQuote(name))
+				if usage != "" { script.WriteString(" -d " + shellQuote(usage)) }
+				script.WriteString("\n")
+			}
+			// flags can't be used after the subcommand name
+			condition = subcondition
+		}
+
+		for _, flag := range node.Flags {
+			script.WriteString(prefix)
+			if condition != "" { script.WriteString(" -n " + shellQuote(condition)) }
+			script.WriteString(" -l " + shellQuote(flag.Name))
+			for _, alias := range flag.Aliases {
+				script.WriteString(" -s " + shellQuote(string(alias)))
+			}
+			if flag.Usage != "" { script.WriteString(" -d " + shellQuote(flag.Usage)) }
+			if flag.TakesValue {
+				script.WriteString(" -r")
+				if flag.Candidates != nil { script.WriteString(fishArgs(flag.Candidates)) }
+			}
+			script.WriteString("\n")
+		}
+
+		if len(node.Subcommands) == 0 && node.Args != nil {
+			script.WriteString(prefix)
+			if condition != "" { script.WriteString(" -n " + shellQuote(condition)) }
+			script.WriteString(fishArgs(node.Args) + "\n")
+		}
+	}
+}
+
+// Returns the fish condition for completions of the given subcommand path.
+func fishCondition(path []string) string {
+	conditions := make([]string, len(path))
+	for i, name := range path {
+		conditions[i] = "__fish_seen_subcommand_from " + name
+	}
+	return strings.Join(conditions, "; and ")
+}
+
+// Returns the fish completion arguments for the given candidates.
+func fishArgs(candidates *CompletionCandidates) string {
+	words := append([]string{}, candidates.Words...)
+	for i, word := range words {
+		words[i] = strings.NewReplacer("\\", "\\\\", " ", "\\ ", "(", "\\(", ")", "\\)", "$", "\\$").Replace(word)
+	}
+	if candidates.Files {
+		if len(candidates.FileExtensions) == 0 {
+			return " -F" + fishWordsArg(words)
+		}
+		for _, ext := range candidates.FileExtensions {
+			words = append(words, "(__fish_complete_suffix ." + ext + ")")
+		}
+	}
+	return fishWordsArg(words)
+}
+
+func fishWordsArg(words []string) string {
+	if len(words) == 0 { return "" }
+	return " -a " + shellQuote(strings.Join(words, " "))
+}
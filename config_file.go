@@ -0,0 +1,217 @@
+package badcli
+
+import "os"
+import "io"
+import "bufio"
+import "errors"
+import "strconv"
+import "strings"
+import "io/fs"
+import "path/filepath"
+
+// Enables loading default flag values from a config file. If
+// configFlagName is not empty, a flag with that name is registered
+// so the user can pass the config file path explicitly. Otherwise, or
+// if the flag is not used, the first existing file in defaultPaths is
+// loaded, if any. See also [CLI.UserConfigPath]().
+//
+// Config files contain "key = value" lines, where keys are long flag
+// names and values are parsed with FlagValue.ParseFromArg(). Keys can
+// only be repeated for flags that implement [RepeatableFlagValue]. Values
+// can be surrounded by double or single quotes. Lines starting with
+// '#' or ';' are comments (there are no inline comments, as '#' is
+// common in values). Sections like "[render]" or "[render.scene]"
+// can be used to set flags for subcommands.
+//
+// Command line arguments take precedence over environment variables,
+// and environment variables take precedence over config files.
+func (self *CLI) EnableConfigFile(configFlagName string, defaultPaths ...string) {
+	if self.configEnabled {
+		panic("config file already enabled")
+	}
+	if configFlagName != "" {
+		usage := "Path to a config file with default values for the flags."
+		self.RegisterFlag(configFlagName, usage, NewFilePath(""))
+	}
+	self.configEnabled = true
+	self.configFlagName = configFlagName
+	self.configPaths = defaultPaths
+}
+
+// Returns the path for the given file name inside the program's
+// directory in the user config dir, like "~/.config/inout/config.ini"
+// (following $XDG_CONFIG_HOME on unix). Returns an empty string if
+// the user config dir can't be determined.
+func (self *CLI) UserConfigPath(fileName string) string {
+	configDir, err := os.UserConfigDir()
+	if err != nil { return "" }
+	return filepath.Join(configDir, self.programName, fileName)
+}
+
+// Loads the config file, if enabled and found, and parses the values
+// for all the flags that haven't been set yet.
+func (self *CLI) applyConfigFile() error {
+	if !self.configEnabled { return nil }
+
+	// find config file path
+	var path string
+	if self.configFlagName != "" && self.FlagExplicitlySet(self.configFlagName) {
+		path = self.GetFlagValue(self.configFlagName).(*FilePath).Value()
+	} else {
+		for _, defaultPath := range self.configPaths {
+			if defaultPath == "" { continue }
+			_, err := os.Stat(defaultPath)
+			if err == nil {
+				path = defaultPath
+				break
+			}
+			if !errors.Is(err, fs.ErrNotExist) {
+				return &ParseError{ Kind: ParseErrConfigFile, File: defaultPath, Err: err }
+			}
+		}
+	}
+	if path == "" { return nil }
+
+	// open and load file
+	file, err := os.Open(path)
+	if err != nil {
+		return &ParseError{ Kind: ParseErrConfigFile, File: path, Err: err }
+	}
+	defer file.Close()
+	return self.loadConfig(path, file)
+}
+
+func (self *CLI) loadConfig(path string, reader io.Reader) error {
+	var configFlag *flag
+	if self.configFlagName != "" {
+		configFlag = self.flags[self.configFlagName]
+	}
+
+	section := self
+	sectionSelected := true // whether the section subcommand is being used
+	seenFlags := make(map[*flag]bool)
+	appliedFlags := make(map[*flag]bool) // for repeatable flags
+	lineNum := 0
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		lineNum += 1
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' || line[0] == ';' { continue }
+
+		// section headers
+		if line[0] == '[' {
+			if !strings.HasSuffix(line, "]") || len(line) == 2 {
+				return &ParseError{
+					Kind: ParseErrConfigFile, File: path, Line: lineNum,
+					Err: errors.New("invalid section header"),
+				}
+			}
+			section, sectionSelected = self, true
+			for _, name := range strings.Split(line[1 : len(line) - 1], ".") {
+				name = strings.TrimSpace(name)
+				subcommand, found := section.subcommands[name]
+				if !found {
+					return &ParseError{
+						Kind: ParseErrUnknownSubcommand, Arg: name, File: path, Line: lineNum,
+						Suggestion: findCloseName(name, section.subcommandNames()),
+					}
+				}
+				sectionSelected = sectionSelected && section.selectedSubcommand == subcommand
+				section = subcommand
+			}
+			continue
+		}
+
+		// key = value lines
+		key, value, hasEquals := strings.Cut(line, "=")
+		if !hasEquals {
+			return &ParseError{
+				Kind: ParseErrConfigFile, File: path, Line: lineNum,
+				Err: errors.New("expected 'key = value' format"),
+			}
+		}
+		key = strings.TrimSpace(key)
+		value, err := unquoteConfigValue(strings.TrimSpace(value))
+		if err != nil {
+			return &ParseError{ Kind: ParseErrConfigFile, Arg: key, File: path, Line: lineNum, Err: err }
+		}
+
+		flagPtr, found := section.findFlag(key)
+		if !found {
+			parseErr := &ParseError{ Kind: ParseErrUnknownFlag, Arg: key, FlagName: key, File: path, Line: lineNum }
+			if key != "" {
+				parseErr.Suggestion = section.FindCloseFlagName(key)
+			}
+			return parseErr
+		}
+		if flagPtr == configFlag {
+			return &ParseError{
+				Kind: ParseErrConfigFile, Arg: key, File: path, Line: lineNum,
+				Err: errors.New("the config file path can't be set from a config file"),
+			}
+		}
+		if seenFlags[flagPtr] && !isRepeatable(flagPtr.Value) {
+			return &ParseError{ Kind: ParseErrDuplicatedFlag, Arg: key, FlagName: key, File: path, Line: lineNum }
+		}
+		seenFlags[flagPtr] = true
+
+		// parse value if relevant
+		if !sectionSelected { continue }
+		if flagPtr.Source != FlagSourceDefault && !appliedFlags[flagPtr] { continue }
+		err = flagPtr.Value.ParseFromArg(value)
+		if err != nil {
+			return &ParseError{
+				Kind: ParseErrInvalidValue, Arg: key, FlagName: key,
+				File: path, Line: lineNum, Err: err,
+			}
+		}
+		flagPtr.Source = FlagSourceFile
+		appliedFlags[flagPtr] = true
+	}
+
+	err := scanner.Err()
+	if err != nil {
+		return &ParseError{ Kind: ParseErrConfigFile, File: path, Err: err }
+	}
+	return nil
+}
+
+// Removes surrounding quotes from config values, if any. Double quoted
+// values support the \" and \\ escapes. Single quoted values are literal.
+func unquoteConfigValue(value string) (string, error) {
+	if value == "" { return value, nil }
+	switch value[0] {
+	case '\'':
+		if len(value) < 2 || value[len(value) - 1] != '\'' {
+			return "", errors.New("unterminated single quoted value")
+		}
+		return value[1 : len(value) - 1], nil
+	case '"':
+		if len(value) < 2 || value[len(value) - 1] != '"' {
+			return "", errors.New("unterminated double quoted value")
+		}
+		var unquoted strings.Builder
+		escaped := false
+		for _, char := range value[1 : len(value) - 1] {
+			if escaped {
+				if char != '"' && char != '\\' {
+					return "", errors.New("invalid escape sequence '\\" + string(char) + "' (only \\\" and \\\\ allowed)")
+				}
+				unquoted.WriteRune(char)
+				escaped = false
+			} else if char == '\\' {
+				escaped = true
+			} else if char == '"' {
+				return "", errors.New("unexpected quote at " + strconv.Quote(value))
+			} else {
+				unquoted.WriteRune(char)
+			}
+		}
+		if escaped {
+			return "", errors.New("unterminated double quoted value")
+		}
+		return unquoted.String(), nil
+	default:
+		return value, nil
+	}
+}
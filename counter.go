@@ -0,0 +1,59 @@
+package badcli
+
+import "errors"
+import "strconv"
+
+// Assert interface compliance.
+var _ SwitchFlagValue = (*Counter)(nil)
+var _ RepeatableFlagValue = (*Counter)(nil)
+var _ ResettableFlagValue = (*Counter)(nil)
+
+// A flag that doesn't take an argument and counts how many times it
+// has been used, typically for verbosity levels like "-vvv". Explicit
+// values like "--verbose=2" set the count directly, and "--no-verbose"
+// resets it to zero.
+type Counter struct {
+	value int
+	max int // negative for no limit
+}
+
+// Creates a counter starting at zero. Use a negative max for no limit.
+func NewCounter(max int) *Counter {
+	return &Counter{ max: max }
+}
+
+func (self Counter) Value() int {
+	return self.value
+}
+
+func (self Counter) String() string {
+	return strconv.Itoa(self.value)
+}
+
+func (self *Counter) IsSwitch() bool { return true }
+func (self *Counter) IsRepeatable() bool { return true }
+
+// Restores the count to zero before each parsing.
+func (self *Counter) Reset() { self.value = 0 }
+
+func (self *Counter) ParseFromArg(arg string) error {
+	var value int
+	switch arg {
+	case "": // flag used without value
+		value = self.value + 1
+	case "false":
+		value = 0
+	default:
+		argInt64, err := strconv.ParseInt(arg, 10, strconv.IntSize)
+		if err != nil || argInt64 < 0 {
+			return errors.New("expected a non-negative count, but got '" + arg + "' instead")
+		}
+		value = int(argInt64)
+	}
+
+	if self.max >= 0 && value > self.max {
+		return errors.New("flag can't be used more than " + strconv.Itoa(self.max) + " times")
+	}
+	self.value = value
+	return nil
+}
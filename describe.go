@@ -0,0 +1,154 @@
+package badcli
+
+import "io"
+import "errors"
+import "reflect"
+import "strings"
+import "encoding/json"
+
+// Returned by [CLI.ParseArgs]() when --help=json is found among the
+// arguments. [CLI.ParseArguments]() prints [CLI.Describe]() as JSON
+// in that case.
+var ErrJSONHelpRequested = errors.New("json help requested")
+
+// Machine-readable description of a [*CLI], as returned by
+// [CLI.Describe](). It can be serialized as JSON.
+type CLIDescription struct {
+	Program string `json:"program"`
+	Description string `json:"description,omitempty"`
+	Usage string `json:"usage,omitempty"` // short description, only for subcommands
+	Synopsis string `json:"synopsis"`
+	Flags []FlagDescription `json:"flags"`
+	Positionals []PositionalDescription `json:"positionals,omitempty"`
+	Constraints []string `json:"constraints,omitempty"`
+	Subcommands []CLIDescription `json:"subcommands,omitempty"`
+	Examples []ExampleDescription `json:"examples,omitempty"`
+	Sections []string `json:"sections,omitempty"` // see CLI.AddUsageSection()
+}
+
+// Machine-readable description of a flag. See [CLIDescription].
+type FlagDescription struct {
+	Name string `json:"name"` // long name, without "--"
+	Aliases []string `json:"aliases,omitempty"` // short aliases, without "-"
+	Usage string `json:"usage"`
+	Type string `json:"type"` // FlagValue type name, like "BoundedInt"
+	Placeholder string `json:"placeholder,omitempty"` // empty for switches
+	Default string `json:"default"` // empty if unknown
+	Required bool `json:"required"`
+	Switch bool `json:"switch"`
+	Repeatable bool `json:"repeatable"`
+	Persistent bool `json:"persistent"`
+	Group string `json:"group,omitempty"`
+	EnvVar string `json:"env,omitempty"`
+}
+
+// Machine-readable description of a positional. See [CLIDescription].
+type PositionalDescription struct {
+	Name string `json:"name"`
+	Usage string `json:"usage"`
+	Type string `json:"type"`
+	Optional bool `json:"optional"`
+	Variadic bool `json:"variadic"`
+	MinCount int `json:"minCount,omitempty"` // only for variadic positionals
+}
+
+// Machine-readable description of an example. See [CLIDescription].
+type ExampleDescription struct {
+	CommandLine string `json:"commandLine"` // without the program name
+	Description string `json:"description,omitempty"`
+}
+
+// Returns a description of the CLI interface and its subcommands,
+// recursively, so wrapper scripts, GUIs or linters can inspect it.
+// Flags include inherited persistent flags, and are given in the
+// same order as [CLI.PrintUsage]() (ungrouped flags first).
+func (self *CLI) Describe() CLIDescription {
+	descr := CLIDescription{
+		Program: self.programName,
+		Description: self.helpDescription,
+		Usage: self.subcommandUsage,
+		Synopsis: self.usageSynopsis(),
+		Flags: []FlagDescription{},
+		Sections: self.extraUsageSections,
+	}
+
+	reverseAliases := self.reverseAliases()
+	for _, group := range self.usageFlagGroups() {
+		for _, flagName := range group.FlagNames {
+			flagPtr, _ := self.findFlag(flagName)
+			var aliases []string
+			for _, alias := range reverseAliases[flagName] {
+				aliases = append(aliases, string(alias))
+			}
+			descr.Flags = append(descr.Flags, FlagDescription{
+				Name: flagName,
+				Aliases: aliases,
+				Usage: flagPtr.Usage,
+				Type: valueTypeName(flagPtr.Value),
+				Placeholder: placeholderOf(flagPtr.Value),
+				Default: flagPtr.Default,
+				Required: flagPtr.Required,
+				Switch: isSwitch(flagPtr.Value),
+				Repeatable: isRepeatable(flagPtr.Value),
+				Persistent: flagPtr.Persistent,
+				Group: group.Name,
+				EnvVar: self.FlagEnvVar(flagName),
+			})
+		}
+	}
+
+	for _, pos := range self.positionals {
+		descr.Positionals = append(descr.Positionals, PositionalDescription{
+			Name: pos.Name,
+			Usage: pos.Usage,
+			Type: valueTypeName(pos.Value),
+			Optional: pos.Optional,
+			Variadic: pos.Variadic,
+			MinCount: pos.MinCount,
+		})
+	}
+	for i, _ := range self.flagConstraints {
+		descr.Constraints = append(descr.Constraints, self.flagConstraints[i].describe(false))
+	}
+	for _, name := range self.subcommandNames() {
+		descr.Subcommands = append(descr.Subcommands, self.subcommands[name].Describe())
+	}
+	for _, example := range self.examples {
+		descr.Examples = append(descr.Examples, ExampleDescription{
+			CommandLine: example.CommandLine,
+			Description: example.Description,
+		})
+	}
+	return descr
+}
+
+// Writes [CLI.Describe]() to the given output as indented JSON.
+func (self *CLI) WriteJSONDescription(output io.Writer) error {
+	encoder := json.NewEncoder(output)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(self.Describe())
+}
+
+// Returns the name of the value's type without pointers nor package
+// paths, like "BoundedInt" or "ValueList[ColorString]".
+func valueTypeName(value FlagValue) string {
+	valueType := reflect.TypeOf(value)
+	for valueType.Kind() == reflect.Pointer {
+		valueType = valueType.Elem()
+	}
+	name := valueType.Name()
+	base, params, isGeneric := strings.Cut(name, "[")
+	if !isGeneric { return name }
+
+	// simplify generic type parameters too
+	params = strings.TrimSuffix(params, "]")
+	paramNames := strings.Split(params, ",")
+	for i, param := range paramNames {
+		param = strings.TrimLeft(strings.TrimSpace(param), "*")
+		if lastDot := strings.LastIndexByte(param, '.'); lastDot != -1 {
+			param = param[lastDot + 1 : ]
+		}
+		paramNames[i] = param
+	}
+	return base + "[" + strings.Join(paramNames, ",") + "]"
+}
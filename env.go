@@ -0,0 +1,94 @@
+package badcli
+
+import "os"
+import "strings"
+
+// Binds a flag to an environment variable. If the flag is not given
+// through command line arguments, the environment variable value will
+// be parsed with the same FlagValue.ParseFromArg() method instead.
+// Empty environment variables are ignored.
+//
+// If envVarName is empty, the name is derived from the program name
+// and the flag name, like "INOUT_COLOR" for "--color" in "inout".
+func (self *CLI) BindEnv(longFlagName, envVarName string) {
+	if !self.IsFlagRegistered(longFlagName) {
+		panic("can't bind environment variable to inexistent '" + longFlagName + "' flag")
+	}
+	if envVarName == "" {
+		envVarName = self.autoEnvVarName(longFlagName)
+	}
+	if strings.ContainsAny(envVarName, "= \t\n") {
+		panic("invalid environment variable name ('" + envVarName + "')")
+	}
+	self.flags[longFlagName].EnvVar = envVarName
+}
+
+// Binds all the flags of the CLI, including flags registered after
+// this call, to environment variables with automatically derived
+// names. See [CLI.BindEnv]() for more details. Explicit bindings
+// are preserved. Subcommands must enable this separately.
+func (self *CLI) AutoBindEnv() {
+	self.envAutoBind = true
+}
+
+// Sets the function used to look up environment variables. Passing
+// nil restores the default, which is os.LookupEnv.
+func (self *CLI) SetLookupEnvFunc(lookupEnvFn func(key string) (string, bool)) {
+	if lookupEnvFn == nil { lookupEnvFn = os.LookupEnv }
+	self.lookupEnvFn = lookupEnvFn
+	for _, subcommand := range self.subcommands {
+		subcommand.SetLookupEnvFunc(lookupEnvFn)
+	}
+}
+
+// Returns the name of the environment variable bound to the given
+// flag, or an empty string if the flag is not bound or doesn't exist.
+func (self *CLI) FlagEnvVar(longFlagName string) string {
+	owner, flagPtr := self.findFlagOwner(longFlagName)
+	if flagPtr == nil { return "" }
+	if flagPtr.EnvVar != "" { return flagPtr.EnvVar }
+	if owner.envAutoBind { return owner.autoEnvVarName(longFlagName) }
+	return ""
+}
+
+// Returns the program name and flag name in upper case, with any
+// character other than ASCII letters and digits replaced by '_'.
+func (self *CLI) autoEnvVarName(longFlagName string) string {
+	var name strings.Builder
+	for _, str := range []string{self.programName, "_", longFlagName} {
+		for _, char := range str {
+			switch {
+			case char >= 'a' && char <= 'z':
+				name.WriteRune(char - 'a' + 'A')
+			case (char >= 'A' && char <= 'Z') || (char >= '0' && char <= '9'):
+				name.WriteRune(char)
+			default:
+				name.WriteByte('_')
+			}
+		}
+	}
+	return name.String()
+}
+
+// Parses environment variable values for all the bound flags that
+// haven't been set yet.
+func (self *CLI) applyEnvValues() error {
+	for _, flagName := range self.availableFlagNames() {
+		flagPtr, _ := self.findFlag(flagName)
+		if flagPtr.Source != FlagSourceDefault { continue }
+		envVarName := self.FlagEnvVar(flagName)
+		if envVarName == "" { continue }
+		envValue, found := self.lookupEnvFn(envVarName)
+		if !found || envValue == "" { continue }
+
+		err := flagPtr.Value.ParseFromArg(envValue)
+		if err != nil {
+			return &ParseError{
+				Kind: ParseErrInvalidValue, Arg: envValue,
+				FlagName: flagName, EnvVar: envVarName, Err: err,
+			}
+		}
+		flagPtr.Source = FlagSourceEnv
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package badcli
+
+import "fmt"
+
+type example struct {
+	CommandLine string // without the program name
+	Description string
+}
+
+// Adds an example usage shown in the "Examples" section of
+// [CLI.PrintUsage](). The command line must not include the program
+// name, and it's split into arguments with basic shell rules, so quotes
+// can be used as usual (e.g. `--color 'rgb(0, 0, 0)' out.png`). The
+// description is optional. See also [CLI.CheckExamples]().
+func (self *CLI) AddExample(commandLine, description string) {
+	self.examples = append(self.examples, example{
+		CommandLine: commandLine,
+		Description: description,
+	})
+}
+
+// Parses all the examples added with [CLI.AddExample](), including
+// the examples of subcommands, and returns an error for the first one
+// that fails. This is meant to be used in tests, so examples don't
+// become stale when flags are renamed or their values change:
+//
+//	func TestExamples(t *testing.T) {
+//		err := newAppCLI().CheckExamples()
+//		if err != nil { t.Fatal(err) }
+//	}
+//
+// Environment variables and config files are ignored while checking,
+// and the flag values of the CLI will be modified.
+func (self *CLI) CheckExamples() error {
+	// examples of subcommands are parsed from the root, so the state
+	// of inherited persistent flags is reset like in real invocations
+	root, path := self, []string{}
+	for root.parent != nil {
+		path = append([]string{root.subcommandName()}, path...)
+		root = root.parent
+	}
+	return self.checkExamples(root, path)
+}
+
+func (self *CLI) checkExamples(root *CLI, path []string) error {
+	for _, example := range self.examples {
+		args, _, err := tokenizeResponseFile(example.CommandLine)
+		if err == nil { err = root.parseArgs(append(append([]string{}, path...), args...)) }
+		if err == nil { err = checkFlagsChain(root.selectedChain()) }
+		if err != nil && err != ErrHelpRequested && err != ErrJSONHelpRequested && err != ErrCompletionRequested {
+			return fmt.Errorf("example '%s %s': %w", self.programName, example.CommandLine, err)
+		}
+	}
+
+	for _, name := range self.subcommandNames() {
+		subpath := append(append([]string{}, path...), name)
+		err := self.subcommands[name].checkExamples(root, subpath)
+		if err != nil { return err }
+	}
+	return nil
+}
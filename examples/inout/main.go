@@ -8,6 +8,9 @@ func main() {
 	cli.AddUsageSection("Additional usage section. Nothing really interesting to say.")
 	cli.RegisterFlag("color" , "Color in hex or rgb format.", badcli.NewColorString(0, 0, 0), 'c')
 	cli.RegisterFlag("number", "Number between 11 and 99.", badcli.NewBoundedInt(0, 11, 99), 'n')
+	cli.RegisterFlag("verbose", "Switch without arguments.", badcli.NewSwitch(false), 'v')
+	cli.AddExample("--color '#fff' -n 42 -v", "Shows all the flags set.")
+	cli.EnableCompletionFlag()
 	//cli.RegisterFlag("regexp" , "Any string ~= /[a-zA-Z0-9]{1-9}/.", badcli.NewRegexp(`[a-zA-Z0-9]{1-9}`))
 	cli.ParseArguments()
 
@@ -24,6 +27,11 @@ func main() {
 		panic("can't happen unless the function passed to cli.EachFlagName() fails")
 	}
 
+	// show extra args, if any
+	for _, extraArg := range cli.ExtraArgs() {
+		fmt.Printf("Extra argument: %s\n", extraArg)
+	}
+
 	// if cli.FlagSetByUser("number") {
 	// 	fmt.Printf("Value of '--number': %s\n", cli.GetFlagValue("color").(*ColorString).String())
 	// } else {
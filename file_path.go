@@ -5,6 +5,11 @@ import "errors"
 import "strings"
 import "path/filepath"
 
+// Assert interface compliance.
+var _ CompletableFlagValue = (*FilePath)(nil)
+var _ PlaceholderFlagValue = (*FilePath)(nil)
+var _ DefaultDescriptionFlagValue = (*FilePath)(nil)
+
 type FilePath struct {
 	value string
 	allowedExtensions []string
@@ -29,6 +34,13 @@ func (self *FilePath) Reference() string {
 	return dir + string(os.PathSeparator) + filepath.Base(self.value)
 }
 
+func (self *FilePath) Placeholder() string { return "PATH" }
+func (self *FilePath) DefaultDescription() string { return self.value }
+
+func (self *FilePath) CompletionCandidates() CompletionCandidates {
+	return CompletionCandidates{ Files: true, FileExtensions: self.allowedExtensions }
+}
+
 func (self *FilePath) ParseFromArg(arg string) error {
 	// empty value case
 	if arg == "" { return ErrMissingValue }
@@ -4,5 +4,46 @@ type flag struct {
 	//Name string // to be used with --
 	Value FlagValue
 	Usage string
-	SetByUser bool
+	Default string // value description at registration, empty if unknown
+	Index int // registration order
+	Group string // empty if not grouped, see CLI.SetFlagGroup()
+	Source FlagSource // FlagSourceDefault until set
+	EnvVar string // explicitly bound environment variable, if any
+	Persistent bool // inherited by subcommands
+	Required bool // parsing fails if not set
+	HasImplicitValue bool // the value can be omitted, see CLI.SetImplicitValue()
+	ImplicitValue string
+}
+
+// Where the value of a flag comes from. See [CLI.FlagSource]().
+type FlagSource uint8
+const (
+	FlagSourceDefault FlagSource = iota // not set, default value
+	FlagSourceCLI // set through command line arguments
+	FlagSourceEnv // set through an environment variable
+	FlagSourceFile // set through a config file
+)
+
+// Returns the precedence of the source when the same flag could be
+// set from multiple sources: cli > env > file > default.
+func (self FlagSource) precedence() int {
+	switch self {
+	case FlagSourceDefault: return 0
+	case FlagSourceFile: return 1
+	case FlagSourceEnv: return 2
+	case FlagSourceCLI: return 3
+	default:
+		panic("unexpected flag source")
+	}
+}
+
+func (self FlagSource) String() string {
+	switch self {
+	case FlagSourceDefault: return "default"
+	case FlagSourceCLI: return "cli"
+	case FlagSourceEnv: return "env"
+	case FlagSourceFile: return "file"
+	default:
+		panic("unexpected flag source")
+	}
 }
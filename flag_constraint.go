@@ -0,0 +1,143 @@
+package badcli
+
+import "strings"
+
+type constraintKind uint8
+const (
+	constraintExclusive constraintKind = iota // at most one flag can be set
+	constraintDependency // if the first flag is set, all the others must be too
+	constraintAtLeastOne // at least one flag must be set
+	constraintExactlyOne // one and only one flag must be set
+)
+
+type flagConstraint struct {
+	Kind constraintKind
+	FlagNames []string // long names
+}
+
+// Registers a group of flags that can't be used together. Values from
+// environment variables or config files count too, unless another flag
+// of the group is set from a higher precedence source (cli > env > file),
+// which overrides them in effect.
+func (self *CLI) RegisterExclusiveFlags(longFlagNames ...string) {
+	self.registerConstraint(constraintExclusive, longFlagNames, 2)
+}
+
+// Registers flags required by the given flag whenever it's used.
+// For example, RegisterFlagDependency("width", "height") will make
+// parsing fail if --width is used without --height.
+func (self *CLI) RegisterFlagDependency(longFlagName string, requiredFlagNames ...string) {
+	flagNames := append([]string{longFlagName}, requiredFlagNames...)
+	self.registerConstraint(constraintDependency, flagNames, 2)
+}
+
+// Registers a group of flags where at least one must be used.
+func (self *CLI) RegisterAtLeastOneFlag(longFlagNames ...string) {
+	self.registerConstraint(constraintAtLeastOne, longFlagNames, 2)
+}
+
+// Registers a group of flags where one and only one must be used.
+// Precedence is handled like in [CLI.RegisterExclusiveFlags]().
+func (self *CLI) RegisterExactlyOneFlag(longFlagNames ...string) {
+	self.registerConstraint(constraintExactlyOne, longFlagNames, 2)
+}
+
+func (self *CLI) registerConstraint(kind constraintKind, longFlagNames []string, minFlags int) {
+	// safety checks
+	if len(longFlagNames) < minFlags {
+		panic("flag constraints require at least two flags")
+	}
+	for i, flagName := range longFlagNames {
+		if _, found := self.findFlag(flagName); !found {
+			panic("can't register constraint for inexistent '" + flagName + "' flag")
+		}
+		for _, prevFlagName := range longFlagNames[ : i] {
+			if prevFlagName == flagName {
+				panic("repeated flag '" + flagName + "' in constraint")
+			}
+		}
+	}
+
+	// actual registration
+	self.flagConstraints = append(self.flagConstraints, flagConstraint{
+		Kind: kind,
+		FlagNames: append([]string(nil), longFlagNames...),
+	})
+}
+
+// Returns whether the constraint is satisfied with the current flags.
+func (self *flagConstraint) isSatisfied(cli *CLI) bool {
+	// values from lower precedence sources are overridden in effect by
+	// other flags of the constraint set from higher precedence sources
+	// (e.g. an env value for --output doesn't conflict with --stdout)
+	topSource := FlagSourceDefault
+	for _, flagName := range self.FlagNames {
+		source := cli.FlagSource(flagName)
+		if source.precedence() > topSource.precedence() { topSource = source }
+	}
+
+	setCount, topSourceCount := 0, 0
+	for _, flagName := range self.FlagNames {
+		source := cli.FlagSource(flagName)
+		if source == FlagSourceDefault { continue }
+		setCount += 1
+		if source == topSource { topSourceCount += 1 }
+	}
+
+	switch self.Kind {
+	case constraintExclusive:
+		return topSourceCount <= 1
+	case constraintDependency:
+		return !cli.FlagExplicitlySet(self.FlagNames[0]) || setCount == len(self.FlagNames)
+	case constraintAtLeastOne:
+		return setCount >= 1
+	case constraintExactlyOne:
+		return topSourceCount == 1
+	default:
+		panic("unexpected constraint kind")
+	}
+}
+
+// Returns a description of the constraint like "--width requires --height".
+// If quote is true, flag names are surrounded by single quotes.
+func (self *flagConstraint) describe(quote bool) string {
+	names := make([]string, len(self.FlagNames))
+	for i, flagName := range self.FlagNames {
+		names[i] = "--" + flagName
+		if quote { names[i] = "'" + names[i] + "'" }
+	}
+
+	switch self.Kind {
+	case constraintExclusive:
+		return listWithConjunction(names, "and") + " can't be used together"
+	case constraintDependency:
+		return names[0] + " requires " + listWithConjunction(names[1 : ], "and")
+	case constraintAtLeastOne:
+		return "at least one of " + listWithConjunction(names, "or") + " is required"
+	case constraintExactlyOne:
+		return "exactly one of " + listWithConjunction(names, "or") + " is required"
+	default:
+		panic("unexpected constraint kind")
+	}
+}
+
+// Returns a [*ParseError] describing all the unsatisfied constraints,
+// or nil if all constraints are satisfied. Constraints registered on
+// parent commands are not checked here.
+func (self *CLI) checkFlagConstraints() error {
+	var violations []string
+	for i, _ := range self.flagConstraints {
+		if !self.flagConstraints[i].isSatisfied(self) {
+			violations = append(violations, self.flagConstraints[i].describe(true))
+		}
+	}
+	if len(violations) == 0 { return nil }
+	return &ParseError{ Kind: ParseErrConstraint, Violations: violations }
+}
+
+// Returns "a", "a and b" or "a, b and c" (with the given conjunction).
+func listWithConjunction(items []string, conjunction string) string {
+	if len(items) <= 1 { return strings.Join(items, "") }
+	last := len(items) - 1
+	return strings.Join(items[ : last], ", ") + " " + conjunction + " " + items[last]
+}
@@ -0,0 +1,86 @@
+package badcli
+
+import "sort"
+
+// Assigns the given flags to a named group, like "Input" or "Color
+// options". [CLI.PrintUsage]() shows each group as a separate headed
+// block after the ungrouped flags, in the order the groups were first
+// used. Flags can only belong to one group, so assigning a flag again
+// moves it to the new group.
+func (self *CLI) SetFlagGroup(groupName string, longFlagNames ...string) {
+	if groupName == "" {
+		panic("flag group name can't be empty")
+	}
+	for _, flagName := range longFlagNames {
+		if !self.IsFlagRegistered(flagName) {
+			panic("can't assign inexistent '" + flagName + "' flag to group '" + groupName + "'")
+		}
+		self.flags[flagName].Group = groupName
+	}
+
+	for _, existingGroup := range self.flagGroups {
+		if existingGroup == groupName { return }
+	}
+	self.flagGroups = append(self.flagGroups, groupName)
+}
+
+// Makes [CLI.PrintUsage]() list flags in registration order instead
+// of alphabetically. Inherited persistent flags are listed after the
+// flags of the CLI itself.
+func (self *CLI) KeepFlagRegistrationOrder() {
+	self.flagsInRegistrationOrder = true
+}
+
+type flagGroup struct {
+	Name string // empty for ungrouped flags
+	FlagNames []string
+}
+
+// Returns the flags available to the CLI split by groups, in the order
+// they must be displayed. The first group is always the ungrouped one,
+// even if empty. Other groups are only returned if they are not empty.
+func (self *CLI) usageFlagGroups() []flagGroup {
+	flagNames := self.availableFlagNames() // alphabetical
+	if self.flagsInRegistrationOrder {
+		depths  := make(map[string]int, len(flagNames))
+		indices := make(map[string]int, len(flagNames))
+		for _, flagName := range flagNames {
+			owner, flagPtr := self.findFlagOwner(flagName)
+			for cli := self; cli != owner; cli = cli.parent {
+				depths[flagName] += 1
+			}
+			indices[flagName] = flagPtr.Index
+		}
+		sort.SliceStable(flagNames, func(i, j int) bool {
+			a, b := flagNames[i], flagNames[j]
+			if depths[a] != depths[b] { return depths[a] < depths[b] }
+			return indices[a] < indices[b]
+		})
+	}
+
+	// collect group names, including inherited ones
+	groups := []flagGroup{ flagGroup{} }
+	groupIndices := make(map[string]int)
+	for cli := self; cli != nil; cli = cli.parent {
+		for _, groupName := range cli.flagGroups {
+			if _, found := groupIndices[groupName]; found { continue }
+			groupIndices[groupName] = len(groups)
+			groups = append(groups, flagGroup{ Name: groupName })
+		}
+	}
+
+	// assign flags to groups
+	for _, flagName := range flagNames {
+		flagPtr, _ := self.findFlag(flagName)
+		index := 0
+		if flagPtr.Group != "" { index = groupIndices[flagPtr.Group] }
+		groups[index].FlagNames = append(groups[index].FlagNames, flagName)
+	}
+
+	// remove empty groups other than the ungrouped one
+	nonEmpty := groups[ : 1]
+	for _, group := range groups[1 : ] {
+		if len(group.FlagNames) > 0 { nonEmpty = append(nonEmpty, group) }
+	}
+	return nonEmpty
+}
@@ -1,5 +1,6 @@
 package badcli
 
+import "fmt"
 import "errors"
 
 type FlagValue interface {
@@ -11,3 +12,112 @@ type FlagValue interface {
 }
 
 var ErrMissingValue = errors.New("missing value")
+
+// Optional interface for flag values that don't take an argument
+// (switches). When a flag value implements this interface and
+// IsSwitch() returns true, [CLI.ParseArguments]() will never consume
+// the next argument for it: ParseFromArg("") is called when the flag
+// is used alone, ParseFromArg("false") for the "--no-flag" form, and
+// any explicit "--flag=value" is passed as is. See [Switch].
+type SwitchFlagValue interface {
+	FlagValue
+	IsSwitch() bool
+}
+
+func isSwitch(value FlagValue) bool {
+	switchValue, ok := value.(SwitchFlagValue)
+	return ok && switchValue.IsSwitch()
+}
+
+// Optional interface for flag values that can be given multiple times.
+// When a flag value implements this interface and IsRepeatable() returns
+// true, [CLI.ParseArguments]() will call ParseFromArg() once for each
+// occurrence of the flag instead of failing. See [ValueList].
+type RepeatableFlagValue interface {
+	FlagValue
+	IsRepeatable() bool
+}
+
+func isRepeatable(value FlagValue) bool {
+	repeatableValue, ok := value.(RepeatableFlagValue)
+	return ok && repeatableValue.IsRepeatable()
+}
+
+// Optional interface for flag values that need to restore their initial
+// state before each parsing, like repeatable values that would otherwise
+// accumulate values across multiple [CLI.ParseArgs]() calls. Reset() is
+// called at the start of parsing for all the flags and positionals of
+// the CLI and its subcommands.
+type ResettableFlagValue interface {
+	FlagValue
+	Reset()
+}
+
+func resetValue(value FlagValue) {
+	if resettableValue, ok := value.(ResettableFlagValue); ok {
+		resettableValue.Reset()
+	}
+}
+
+// Optional interface for flag values that need to validate their
+// final state after parsing (e.g., a minimum number of repetitions).
+// Validate() is called once for each flag after all the arguments,
+// environment variables and config files have been processed, even
+// if the flag hasn't been set.
+type ValidatedFlagValue interface {
+	FlagValue
+	Validate() error
+}
+
+// Optional interface for flag values that can suggest candidates
+// for shell completion. See [CLI.WriteCompletionScript]().
+type CompletableFlagValue interface {
+	FlagValue
+	CompletionCandidates() CompletionCandidates
+}
+
+// Describes the shell completion candidates for a flag value.
+type CompletionCandidates struct {
+	Words []string // fixed candidates, like the choices of an enum
+	Files bool // complete file paths
+	FileExtensions []string // if Files is set, only show these (without '.')
+}
+
+// Optional interface for flag values that want a custom name for
+// their argument in [CLI.PrintUsage](), like "N" in "--number, -n N".
+// Values without it use "VALUE". Switches never show placeholders.
+type PlaceholderFlagValue interface {
+	FlagValue
+	Placeholder() string
+}
+
+// Optional interface for flag values that want to describe their
+// default value in [CLI.PrintUsage]() and generated docs. Values
+// without it fall back to fmt.Stringer, if implemented. Empty
+// descriptions are not shown.
+type DefaultDescriptionFlagValue interface {
+	FlagValue
+	DefaultDescription() string
+}
+
+// Returns the placeholder for the value's argument, or an empty
+// string for switches.
+func placeholderOf(value FlagValue) string {
+	if isSwitch(value) { return "" }
+	if placeholderValue, ok := value.(PlaceholderFlagValue); ok {
+		return placeholderValue.Placeholder()
+	}
+	return "VALUE"
+}
+
+// Returns a description of the current value, to be used as the
+// default value in docs, or an empty string if not available.
+func describeDefault(value FlagValue) string {
+	if describedValue, ok := value.(DefaultDescriptionFlagValue); ok {
+		return describedValue.DefaultDescription()
+	}
+	if stringer, ok := value.(fmt.Stringer); ok {
+		return stringer.String()
+	}
+	return ""
+}
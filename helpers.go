@@ -26,3 +26,9 @@ func hasAnySuffix(str string, suffixes ...string) bool {
 	}
 	return false
 }
+
+// Returns whether the argument looks like a flag or the "--" terminator.
+// A single "-" is often used for stdin / stdout, so it's not included.
+func isFlagShaped(arg string) bool {
+	return len(arg) > 1 && arg[0] == '-'
+}
@@ -0,0 +1,146 @@
+package badcli
+
+import "io"
+import "strconv"
+import "strings"
+
+// Writes a man page for the CLI in roff format, using the man(7)
+// macros, to the given output. The page is generated from the same
+// metadata as [CLI.PrintUsage](): the program name, help description,
+// flags and aliases, positionals, flag constraints, subcommands,
+// examples and extra usage sections. The section is usually 1 for
+// user commands. The result can be previewed with "man ./prog.1".
+func (self *CLI) WriteManPage(output io.Writer, section int) error {
+	var page strings.Builder
+	name := strings.ReplaceAll(self.programName, " ", "-")
+
+	// title and name
+	page.WriteString(".TH " + roffEscape(strings.ToUpper(name)) + " " + strconv.Itoa(section) + "\n")
+	page.WriteString(".SH NAME\n")
+	page.WriteString(roffEscape(name))
+	if summary := firstLine(self.helpDescription); summary != "" {
+		page.WriteString(" \\- " + roffEscape(summary))
+	}
+	page.WriteString("\n")
+
+	// synopsis
+	page.WriteString(".SH SYNOPSIS\n")
+	page.WriteString(".B " + roffEscape(self.programName) + "\n")
+	if len(self.availableFlagNames()) > 0 {
+		page.WriteString("[\\fIflags\\fR]\n")
+	}
+	if len(self.positionals) > 0 {
+		page.WriteString(roffEscape(self.positionalsScheme()) + "\n")
+	} else if len(self.subcommands) > 0 {
+		page.WriteString("\\fIcommand\\fR [\\fIargs\\fR]\n")
+	}
+
+	// description
+	if self.helpDescription != "" {
+		page.WriteString(".SH DESCRIPTION\n")
+		page.WriteString(roffText(self.helpDescription))
+	}
+
+	// options, with groups as subsections
+	if len(self.availableFlagNames()) > 0 {
+		reverseAliases := self.reverseAliases()
+		page.WriteString(".SH OPTIONS\n")
+		for _, group := range self.usageFlagGroups() {
+			if len(group.FlagNames) == 0 { continue }
+			if group.Name != "" {
+				page.WriteString(".SS " + roffEscape(group.Name) + "\n")
+			}
+			entries := self.flagUsageEntries(group.FlagNames)
+			for i, flagName := range group.FlagNames {
+				page.WriteString(".TP\n")
+				page.WriteString("\\fB\\-\\-" + roffEscape(flagName) + "\\fR")
+				for _, alias := range reverseAliases[flagName] {
+					page.WriteString(", \\fB\\-" + roffEscape(string(alias)) + "\\fR")
+				}
+				flagPtr, _ := self.findFlag(flagName)
+				if placeholder := placeholderOf(flagPtr.Value); placeholder != "" {
+					placeholder = "\\fI" + roffEscape(placeholder) + "\\fR"
+					if flagPtr.HasImplicitValue {
+						page.WriteString("[=" + placeholder + "]")
+					} else {
+						page.WriteString(" " + placeholder)
+					}
+				}
+				page.WriteString("\n")
+				page.WriteString(roffText(entries[i].Descr))
+			}
+		}
+	}
+
+	// positionals
+	if len(self.positionals) > 0 {
+		page.WriteString(".SH ARGUMENTS\n")
+		for _, pos := range self.positionals {
+			page.WriteString(".TP\n\\fI" + roffEscape(pos.Name) + "\\fR\n")
+			page.WriteString(roffText(pos.Usage))
+		}
+	}
+
+	// flag constraints
+	if len(self.flagConstraints) > 0 {
+		page.WriteString(".SH \"FLAG CONSTRAINTS\"\n")
+		for i, _ := range self.flagConstraints {
+			if i > 0 { page.WriteString(".br\n") }
+			page.WriteString(roffText(self.flagConstraints[i].describe(false)))
+		}
+	}
+
+	// subcommands
+	if len(self.subcommands) > 0 {
+		page.WriteString(".SH COMMANDS\n")
+		for _, entry := range self.subcommandUsageEntries() {
+			page.WriteString(".TP\n\\fB" + roffEscape(entry.Left) + "\\fR\n")
+			page.WriteString(roffText(entry.Descr))
+		}
+	}
+
+	// examples
+	if len(self.examples) > 0 {
+		page.WriteString(".SH EXAMPLES\n")
+		for _, example := range self.examples {
+			page.WriteString(".TP\n\\fB" + roffEscape(self.programName + " " + example.CommandLine) + "\\fR\n")
+			page.WriteString(roffText(example.Description))
+		}
+	}
+
+	// extra usage sections
+	if len(self.extraUsageSections) > 0 {
+		page.WriteString(".SH NOTES\n")
+		for i, section := range self.extraUsageSections {
+			if i > 0 { page.WriteString(".PP\n") }
+			page.WriteString(roffText(section))
+		}
+	}
+
+	_, err := io.WriteString(output, page.String())
+	return err
+}
+
+// Escapes the characters with special meaning in roff text: backslashes
+// and dashes, which would otherwise be rendered as hyphens.
+func roffEscape(text string) string {
+	return strings.NewReplacer("\\", "\\e", "-", "\\-").Replace(text)
+}
+
+// Escapes the given text and formats it as roff lines. Lines that
+// would be interpreted as requests are protected with a zero-width
+// character, and blank lines are turned into vertical spaces.
+func roffText(text string) string {
+	if text == "" { return "" }
+	var lines strings.Builder
+	for _, line := range strings.Split(roffEscape(text), "\n") {
+		if strings.TrimSpace(line) == "" {
+			lines.WriteString(".sp\n")
+			continue
+		}
+		if line[0] == '.' || line[0] == '\'' { lines.WriteString("\\&") }
+		lines.WriteString(line)
+		lines.WriteByte('\n')
+	}
+	return lines.String()
+}
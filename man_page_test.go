@@ -0,0 +1,57 @@
+package badcli
+
+import "os"
+import "testing"
+import "strings"
+import "path/filepath"
+
+// Set UPDATE_GOLDEN=1 to rewrite the golden files with the current output.
+func checkGoldenFile(t *testing.T, fileName string, output string) {
+	t.Helper()
+	path := filepath.Join("testdata", fileName)
+	if os.Getenv("UPDATE_GOLDEN") == "1" {
+		err := os.WriteFile(path, []byte(output), 0644)
+		if err != nil { t.Fatal(err) }
+	}
+	expected, err := os.ReadFile(path)
+	if err != nil { t.Fatal(err) }
+	if output != string(expected) {
+		t.Fatalf("output doesn't match '%s':\n%s", path, output)
+	}
+}
+
+func TestManPage(t *testing.T) {
+	cli := NewCLI("test", "Test program.\n\n.dots and 'quotes at the start of a line\n'must be escaped, and so do back\\slashes.")
+	cli.RegisterFlag("color" , "Color in hex or rgb format.", NewColorString(0, 0, 0), 'c')
+	cli.RegisterRequiredFlag("number", "Number between 11 and 99.", NewBoundedInt(0, 11, 99), 'n')
+	cli.RegisterFlag("verbose", "Verbose output.", NewSwitch(false), 'v')
+	cli.RegisterFlag("dry-run", "Don't write any files.", NewSwitch(false))
+	cli.RegisterFlag("output", "Output file.", NewFilePath("", "png"), 'o')
+	cli.SetFlagGroup("Files", "output")
+	cli.RegisterExclusiveFlags("verbose", "dry-run")
+	cli.RegisterPositional("input", "Input file.", NewFilePath("", "png"))
+	cli.AddExample("-n 12 -o out.png in.png", "Write the output to 'out.png'.")
+	cli.AddUsageSection("Colors can be given as '#RRGGBB' or 'rgb(R, G, B)'.")
+
+	var page strings.Builder
+	err := cli.WriteManPage(&page, 1)
+	if err != nil { t.Fatal(err) }
+	checkGoldenFile(t, "test.1", page.String())
+
+	// subcommands
+	cli = NewCLI("tool", "Test tool.")
+	cli.RegisterPersistentFlag("verbose", "Verbose output.", NewSwitch(false), 'v')
+	render := cli.RegisterSubcommand("render", "Render an image.", "Renders an image.")
+	render.RegisterFlag("scale", "Scaling factor.", NewBoundedInt(1, 1, 8), 's')
+	cli.RegisterSubcommand("inspect", "Inspect an image.", "Inspects an image.")
+
+	page.Reset()
+	err = cli.WriteManPage(&page, 1)
+	if err != nil { t.Fatal(err) }
+	checkGoldenFile(t, "tool.1", page.String())
+
+	page.Reset()
+	err = render.WriteManPage(&page, 1)
+	if err != nil { t.Fatal(err) }
+	checkGoldenFile(t, "tool-render.1", page.String())
+}
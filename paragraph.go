@@ -50,10 +50,15 @@ retry:
 					if err != nil { return err }
 					fragment.StartIndex = lineEnd
 					lineEnd = fragment.EndIndex
-				} else {
-					// flush current line and jump to next
+				} else if fragment.CanOmitAtEnd {
+					// flush current line and skip the trailing spaces
 					err := flushLine(fragment.EndIndex)
 					if err != nil { return err }
+					continue
+				} else {
+					// flush current line and move the fragment to the next
+					err := flushLine(fragment.StartIndex)
+					if err != nil { return err }
 				}
 				goto retry
 			}
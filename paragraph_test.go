@@ -0,0 +1,38 @@
+package badcli
+
+import "testing"
+import "strings"
+
+func TestEachLineKeepsContent(t *testing.T) {
+	tests := []struct{
+		in string
+		maxLen int
+	}{
+		{"aaa bbb ccc ddd eee fff", 10},
+		{"aaa bbb ccc ddd eee fff", 20},
+		{"flag alias 'n' takes a value, so it can only be the last letter of combined single dash flags", 20},
+		{"flag alias 'n' takes a value, so it can only be the last letter of combined single dash flags", 74},
+		{"Given some flags, inout prints the passed values.\nSecond paragraph with a few more words.", 30},
+	}
+
+	var removeSpaces = func(str string) string {
+		return strings.NewReplacer(" ", "", "\n", "").Replace(str)
+	}
+
+	for i, test := range tests {
+		var lines []string
+		EachLine(test.in, test.maxLen, func(line string) error {
+			if len(line) > test.maxLen {
+				t.Fatalf("test#%d, EachLine(\"%s\", %d) line too long: \"%s\"", i, test.in, test.maxLen, line)
+			}
+			lines = append(lines, line)
+			return nil
+		})
+		
+		result := removeSpaces(strings.Join(lines, ""))
+		expected := removeSpaces(test.in)
+		if result != expected {
+			t.Fatalf("test#%d, EachLine(\"%s\", %d) lost content: %q", i, test.in, test.maxLen, lines)
+		}
+	}
+}
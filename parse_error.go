@@ -0,0 +1,135 @@
+package badcli
+
+import "errors"
+import "strconv"
+import "strings"
+
+// Returned by [CLI.ParseArgs]() when -h, --help or /? are found
+// among the arguments. Parsing stops immediately in that case.
+// The other requests that stop parsing without being errors are
+// [ErrJSONHelpRequested] and [ErrCompletionRequested].
+var ErrHelpRequested = errors.New("help requested")
+
+// The kind of a [*ParseError].
+type ParseErrorKind uint8
+const (
+	ParseErrUnknownFlag ParseErrorKind = iota + 1 // long flag name not registered
+	ParseErrUnknownAlias // short flag alias not registered
+	ParseErrMisplacedAlias // value-taking alias not last in combined single dash flags
+	ParseErrDuplicatedFlag // flag given more than once
+	ParseErrInvalidValue // the flag value failed to parse, see ParseError.Err
+	ParseErrUnexpectedArg // extra argument given when disallowed
+	ParseErrUnknownSubcommand // subcommand name not registered
+	ParseErrMissingRequired // required flags not given, see ParseError.MissingFlags
+	ParseErrConstraint // flag constraints not satisfied, see ParseError.Violations
+	ParseErrConfigFile // config file can't be read or has invalid syntax, see ParseError.Err
+	ParseErrResponseFile // response file can't be read or has invalid syntax, see ParseError.Err
+	ParseErrMissingPositional // not enough arguments for the positionals, see ParseError.Positional
+)
+
+// Errors returned by [CLI.ParseArgs]() are always of this type, except
+// for the sentinels used to signal requests instead of errors:
+// [ErrHelpRequested], [ErrJSONHelpRequested] and [ErrCompletionRequested].
+type ParseError struct {
+	Kind ParseErrorKind
+	Arg string // the full argument as given by the user
+	Value string // the next argument, only if it was consumed as the flag value
+	FlagName string // long flag name, if known (for unknown flags, the name given)
+	Positional string // positional name, if the error is related to it
+	EnvVar string // environment variable name, if the value came from it
+	File string // config or response file path, if the error comes from it
+	Line int // file line number, if relevant (zero otherwise)
+	Alias rune // short flag alias, if relevant (zero otherwise)
+	Suggestion string // close flag or subcommand name for unknown names (may be empty)
+	Err error // the FlagValue.ParseFromArg() error, for ParseErrInvalidValue
+	MissingFlags []string // long flag names, for ParseErrMissingRequired
+	Violations []string // descriptions of unsatisfied constraints, for ParseErrConstraint
+}
+
+func (self *ParseError) Error() string {
+	context, reason := self.describe()
+	msg := "failed to parse " + context + ": " + strings.ReplaceAll(reason, "\n", "; ")
+	if self.Suggestion != "" {
+		msg += " (maybe you meant '" + self.suggestionText() + "'?)"
+	}
+	return msg
+}
+
+// Returns the suggestion as it should be typed by the user.
+func (self *ParseError) suggestionText() string {
+	if self.Kind == ParseErrUnknownSubcommand || self.File != "" {
+		return self.Suggestion
+	}
+	return "--" + self.Suggestion
+}
+
+func (self *ParseError) Unwrap() error {
+	return self.Err
+}
+
+// Returns what was being parsed and why it failed, separately,
+// so they can be formatted in different ways.
+func (self *ParseError) describe() (string, string) {
+	context := "'" + self.Arg + "' argument"
+	if self.File != "" {
+		location := self.File
+		if self.Line > 0 { location += ":" + strconv.Itoa(self.Line) }
+		if self.Kind == ParseErrResponseFile {
+			context = "response file '" + location + "'"
+		} else {
+			context = "config file '" + location + "'"
+			if self.Arg != "" { context = "'" + self.Arg + "' in " + context }
+		}
+	} else if self.EnvVar != "" {
+		context = "'" + self.EnvVar + "' environment variable"
+	} else if self.Positional != "" && self.Kind == ParseErrInvalidValue {
+		context = "'" + self.Arg + "' argument for <" + self.Positional + ">"
+	} else if self.Value != "" {
+		context = "'" + self.Arg + " " + self.Value + "' arguments"
+	}
+
+	switch self.Kind {
+	case ParseErrUnknownFlag:
+		if self.FlagName == "" { return context, "missing flag name" }
+		return context, "flag name not recognized"
+	case ParseErrUnknownAlias:
+		return context, "flag alias '" + string(self.Alias) + "' not recognized"
+	case ParseErrMisplacedAlias:
+		return context, "flag alias '" + string(self.Alias) + "' takes a value, so it can " +
+			"only be the last letter of combined single dash flags"
+	case ParseErrDuplicatedFlag:
+		return context, "duplicated flag (program flags can't be repeated)"
+	case ParseErrInvalidValue:
+		flagArg, _, _ := strings.Cut(self.Arg, "=")
+		if self.Alias != 0 && flagArg != "-" + string(self.Alias) {
+			context = "'-" + string(self.Alias) + "' in " + context
+		}
+		if self.Err == nil { return context, "invalid value" }
+		return context, self.Err.Error()
+	case ParseErrUnexpectedArg:
+		return context, "unexpected argument (extra arguments not allowed)"
+	case ParseErrUnknownSubcommand:
+		return context, "command not recognized"
+	case ParseErrMissingRequired:
+		var reason string
+		if len(self.MissingFlags) == 1 {
+			reason = "missing required flag "
+		} else {
+			reason = "missing required flags "
+		}
+		for i, flagName := range self.MissingFlags {
+			if i > 0 { reason += ", " }
+			reason += "'--" + flagName + "'"
+		}
+		return "arguments", reason
+	case ParseErrMissingPositional:
+		return "arguments", "missing <" + self.Positional + "> argument"
+	case ParseErrConstraint:
+		return "arguments", strings.Join(self.Violations, "\n")
+	case ParseErrConfigFile, ParseErrResponseFile:
+		if self.Err == nil { return context, "invalid file" }
+		return context, self.Err.Error()
+	default:
+		panic("unexpected parse error kind")
+	}
+}
@@ -0,0 +1,144 @@
+package badcli
+
+import "strings"
+
+type positional struct {
+	Name string
+	Usage string
+	Value FlagValue
+	Optional bool
+	Variadic bool
+	MinCount int // only for variadic positionals
+	SetByUser bool
+}
+
+// Registers a required positional argument. Positional arguments are
+// assigned in registration order from the arguments that are not flags,
+// and their values are parsed with the same FlagValue types used for
+// flags. Once positionals are registered, missing arguments and
+// arguments not matching any positional make parsing fail, and
+// [CLI.PrintUsage]() shows the usage scheme ("prog [flags] <input>").
+// Raw strings remain available through [CLI.ExtraArgs]().
+func (self *CLI) RegisterPositional(name, usage string, value FlagValue) {
+	self.registerPositional(&positional{ Name: name, Usage: usage, Value: value })
+}
+
+// Like [CLI.RegisterPositional](), but the argument can be omitted.
+// Optional positionals can't be followed by required ones.
+func (self *CLI) RegisterOptionalPositional(name, usage string, value FlagValue) {
+	self.registerPositional(&positional{ Name: name, Usage: usage, Value: value, Optional: true })
+}
+
+// Registers a positional that takes all the remaining arguments, calling
+// ParseFromArg() once for each of them. The value must implement
+// [RepeatableFlagValue] (e.g. [ValueList]), and the variadic positional
+// must be the last one. At least minCount arguments will be required.
+func (self *CLI) RegisterVariadicPositional(name, usage string, value FlagValue, minCount int) {
+	if !isRepeatable(value) {
+		panic("variadic positional '" + name + "' value must be repeatable")
+	}
+	self.registerPositional(&positional{
+		Name: name, Usage: usage, Value: value,
+		Optional: minCount <= 0, Variadic: true, MinCount: minCount,
+	})
+}
+
+func (self *CLI) registerPositional(pos *positional) {
+	// safety checks
+	if pos.Name == "" || strings.ContainsAny(pos.Name, " \t\n<>[]") {
+		panic("invalid positional name ('" + pos.Name + "')")
+	}
+	if pos.Value == nil {
+		panic("can't register positional with nil value")
+	}
+	if len(self.subcommands) > 0 {
+		panic("can't register positionals on a CLI with subcommands")
+	}
+	for _, prev := range self.positionals {
+		if prev.Name == pos.Name {
+			panic("positional already registered ('" + pos.Name + "')")
+		}
+		if prev.Variadic {
+			panic("can't register positional '" + pos.Name + "' after variadic positional '" + prev.Name + "'")
+		}
+		if prev.Optional && !pos.Optional {
+			panic("can't register required positional '" + pos.Name + "' after optional positional '" + prev.Name + "'")
+		}
+	}
+
+	// actual registration
+	self.positionals = append(self.positionals, pos)
+}
+
+// Returns the value of the given positional, or nil if it doesn't exist.
+func (self *CLI) GetPositionalValue(name string) FlagValue {
+	pos := self.findPositional(name)
+	if pos == nil { return nil }
+	return pos.Value
+}
+
+// Returns whether the given positional has been given by the user.
+// Only relevant for optional and variadic positionals.
+func (self *CLI) PositionalSetByUser(name string) bool {
+	pos := self.findPositional(name)
+	return pos != nil && pos.SetByUser
+}
+
+func (self *CLI) findPositional(name string) *positional {
+	for _, pos := range self.positionals {
+		if pos.Name == name { return pos }
+	}
+	return nil
+}
+
+// Assigns and parses the extra args into the registered positionals.
+func (self *CLI) parsePositionals() error {
+	if len(self.positionals) == 0 { return nil }
+
+	argIndex := 0
+	for _, pos := range self.positionals {
+		count := 1
+		if pos.Variadic { count = len(self.extraArgs) - argIndex }
+		if argIndex + count > len(self.extraArgs) || (pos.Variadic && count < pos.MinCount) {
+			if pos.Optional { break }
+			return &ParseError{ Kind: ParseErrMissingPositional, Positional: pos.Name }
+		}
+
+		for i := 0; i < count; i++ {
+			arg := self.extraArgs[argIndex]
+			err := pos.Value.ParseFromArg(arg)
+			if err != nil {
+				return &ParseError{ Kind: ParseErrInvalidValue, Arg: arg, Positional: pos.Name, Err: err }
+			}
+			argIndex += 1
+		}
+		pos.SetByUser = (count > 0)
+	}
+
+	if argIndex < len(self.extraArgs) {
+		return &ParseError{ Kind: ParseErrUnexpectedArg, Arg: self.extraArgs[argIndex] }
+	}
+	return nil
+}
+
+// Returns the usage scheme for the positionals, like
+// "<input> [<scale>] [<rest>...]".
+func (self *CLI) positionalsScheme() string {
+	var scheme strings.Builder
+	for i, pos := range self.positionals {
+		if i > 0 { scheme.WriteByte(' ') }
+		if pos.Optional { scheme.WriteByte('[') }
+		scheme.WriteString("<" + pos.Name + ">")
+		if pos.Variadic { scheme.WriteString("...") }
+		if pos.Optional { scheme.WriteByte(']') }
+	}
+	return scheme.String()
+}
+
+func (self *CLI) positionalUsageEntries() []usageEntry {
+	entries := make([]usageEntry, 0, len(self.positionals))
+	for _, pos := range self.positionals {
+		entries = append(entries, usageEntry{ Left: "<" + pos.Name + ">", Descr: pos.Usage })
+	}
+	return entries
+}
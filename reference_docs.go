@@ -0,0 +1,280 @@
+package badcli
+
+import "io"
+import "html"
+import "strings"
+
+// Writes a Markdown reference of the CLI and all its subcommands to
+// the given output. The reference includes the usage synopsis, flags
+// with their aliases and defaults, positionals, flag constraints,
+// subcommands, examples and extra usage sections, in the same order
+// and with the same descriptions shown by [CLI.PrintUsage]().
+func (self *CLI) WriteMarkdownReference(output io.Writer) error {
+	var doc markdownReference
+	self.writeReference(&doc, 1)
+	_, err := io.WriteString(output, doc.String())
+	return err
+}
+
+// Like [CLI.WriteMarkdownReference](), but writes plain HTML instead.
+// The result is an HTML fragment without <html> nor <body> tags, so it
+// can be embedded in other pages.
+func (self *CLI) WriteHTMLReference(output io.Writer) error {
+	var doc htmlReference
+	self.writeReference(&doc, 1)
+	_, err := io.WriteString(output, doc.String())
+	return err
+}
+
+// A table cell for reference docs. If Codes is not empty, the cell
+// contains a list of code spans, and Text is ignored.
+type referenceCell struct {
+	Text string
+	Codes []string
+}
+
+// Output format for reference docs. See [CLI.writeReference]().
+type referenceWriter interface {
+	Heading(level int, text string)
+	Paragraph(text string)
+	CodeBlock(lines []string)
+	List(items []string)
+	Table(header []string, rows [][]referenceCell)
+}
+
+// Writes the reference of the CLI with its title at the given heading
+// level, followed by the references of its subcommands, recursively.
+func (self *CLI) writeReference(doc referenceWriter, level int) {
+	doc.Heading(level, self.programName)
+	if self.helpDescription != "" { doc.Paragraph(self.helpDescription) }
+	doc.Heading(level + 1, "Usage")
+	doc.CodeBlock([]string{self.usageSynopsis()})
+
+	// flags, with groups as separate tables
+	reverseAliases := self.reverseAliases()
+	for _, group := range self.usageFlagGroups() {
+		if len(group.FlagNames) == 0 { continue }
+		if group.Name == "" {
+			doc.Heading(level + 1, "Flags")
+		} else {
+			doc.Heading(level + 1, group.Name)
+		}
+		rows := make([][]referenceCell, 0, len(group.FlagNames))
+		for _, flagName := range group.FlagNames {
+			flagPtr, _ := self.findFlag(flagName)
+			forms := []string{"--" + flagName}
+			for _, alias := range reverseAliases[flagName] {
+				forms = append(forms, "-" + string(alias))
+			}
+			if placeholder := placeholderOf(flagPtr.Value); placeholder != "" {
+				if flagPtr.HasImplicitValue {
+					forms[len(forms) - 1] += "[=" + placeholder + "]"
+				} else {
+					forms[len(forms) - 1] += " " + placeholder
+				}
+			}
+			defaultCell := referenceCell{}
+			if showsDefault(flagPtr) { defaultCell.Codes = []string{flagPtr.Default} }
+			descr := flagPtr.Usage + self.flagUsageNotes(flagName)
+			rows = append(rows, []referenceCell{
+				referenceCell{ Codes: forms }, defaultCell, referenceCell{ Text: descr },
+			})
+		}
+		doc.Table([]string{"Flag", "Default", "Description"}, rows)
+	}
+
+	// positionals
+	if len(self.positionals) > 0 {
+		doc.Heading(level + 1, "Arguments")
+		doc.Table([]string{"Argument", "Description"}, usageEntryRows(self.positionalUsageEntries()))
+	}
+
+	// flag constraints
+	if len(self.flagConstraints) > 0 {
+		doc.Heading(level + 1, "Flag constraints")
+		items := make([]string, len(self.flagConstraints))
+		for i, _ := range self.flagConstraints {
+			items[i] = self.flagConstraints[i].describe(false)
+		}
+		doc.List(items)
+	}
+
+	// subcommands
+	if len(self.subcommands) > 0 {
+		doc.Heading(level + 1, "Commands")
+		doc.Table([]string{"Command", "Description"}, usageEntryRows(self.subcommandUsageEntries()))
+	}
+
+	// examples
+	if len(self.examples) > 0 {
+		doc.Heading(level + 1, "Examples")
+		for _, example := range self.examples {
+			doc.CodeBlock([]string{self.programName + " " + example.CommandLine})
+			if example.Description != "" { doc.Paragraph(example.Description) }
+		}
+	}
+
+	// extra usage sections
+	for _, section := range self.extraUsageSections {
+		doc.Paragraph(section)
+	}
+
+	// subcommand references
+	for _, name := range self.subcommandNames() {
+		self.subcommands[name].writeReference(doc, level + 1)
+	}
+}
+
+// Returns the usage synopsis, like "prog [flags] <input>".
+func (self *CLI) usageSynopsis() string {
+	synopsis := self.programName
+	if len(self.availableFlagNames()) > 0 { synopsis += " [flags]" }
+	if len(self.positionals) > 0 {
+		synopsis += " " + self.positionalsScheme()
+	} else if len(self.subcommands) > 0 {
+		synopsis += " <command> [args]"
+	}
+	return synopsis
+}
+
+func usageEntryRows(entries []usageEntry) [][]referenceCell {
+	rows := make([][]referenceCell, len(entries))
+	for i, entry := range entries {
+		rows[i] = []referenceCell{
+			referenceCell{ Codes: []string{entry.Left} }, referenceCell{ Text: entry.Descr },
+		}
+	}
+	return rows
+}
+
+// --- markdown ---
+
+type markdownReference struct {
+	strings.Builder
+}
+
+func (self *markdownReference) block(text string) {
+	if self.Len() > 0 { self.WriteString("\n") }
+	self.WriteString(text)
+}
+
+func (self *markdownReference) Heading(level int, text string) {
+	self.block(strings.Repeat("#", level) + " " + markdownEscape(text) + "\n")
+}
+
+func (self *markdownReference) Paragraph(text string) {
+	self.block(markdownEscape(text) + "\n")
+}
+
+func (self *markdownReference) CodeBlock(lines []string) {
+	fence := "```"
+	for _, line := range lines {
+		for strings.Contains(line, fence) { fence += "`" }
+	}
+	self.block(fence + "\n" + strings.Join(lines, "\n") + "\n" + fence + "\n")
+}
+
+func (self *markdownReference) List(items []string) {
+	var list strings.Builder
+	for _, item := range items {
+		list.WriteString("- " + markdownEscape(strings.ReplaceAll(item, "\n", " ")) + "\n")
+	}
+	self.block(list.String())
+}
+
+func (self *markdownReference) Table(header []string, rows [][]referenceCell) {
+	var table strings.Builder
+	table.WriteString("| " + strings.Join(header, " | ") + " |\n")
+	table.WriteString(strings.Repeat("| --- ", len(header)) + "|\n")
+	for _, row := range rows {
+		table.WriteString("|")
+		for _, cell := range row {
+			table.WriteString(" ")
+			if len(cell.Codes) > 0 {
+				for i, code := range cell.Codes {
+					if i > 0 { table.WriteString(", ") }
+					table.WriteString(markdownCode(code))
+				}
+			} else {
+				table.WriteString(markdownEscape(strings.ReplaceAll(cell.Text, "\n", " ")))
+			}
+			table.WriteString(" |")
+		}
+		table.WriteString("\n")
+	}
+	self.block(table.String())
+}
+
+// Escapes the characters with special meaning in Markdown text.
+var markdownReplacer = strings.NewReplacer(
+	"\\", "\\\\", "`", "\\`", "*", "\\*", "_", "\\_", "[", "\\[", "]", "\\]",
+	"<", "\\<", ">", "\\>", "|", "\\|", "#", "\\#",
+)
+
+func markdownEscape(text string) string {
+	return markdownReplacer.Replace(text)
+}
+
+// Returns the text as a Markdown code span. Pipes are still escaped,
+// as they would otherwise break tables.
+func markdownCode(text string) string {
+	fence := "`"
+	for strings.Contains(text, fence) { fence += "`" }
+	if strings.HasPrefix(text, "`") || strings.HasSuffix(text, "`") {
+		text = " " + text + " "
+	}
+	return fence + strings.ReplaceAll(text, "|", "\\|") + fence
+}
+
+// --- html ---
+
+type htmlReference struct {
+	strings.Builder
+}
+
+func (self *htmlReference) Heading(level int, text string) {
+	if level > 6 { level = 6 }
+	tag := "h" + string(rune('0' + level))
+	self.WriteString("<" + tag + ">" + html.EscapeString(text) + "</" + tag + ">\n")
+}
+
+func (self *htmlReference) Paragraph(text string) {
+	self.WriteString("<p>" + html.EscapeString(text) + "</p>\n")
+}
+
+func (self *htmlReference) CodeBlock(lines []string) {
+	self.WriteString("<pre><code>" + html.EscapeString(strings.Join(lines, "\n")) + "</code></pre>\n")
+}
+
+func (self *htmlReference) List(items []string) {
+	self.WriteString("<ul>\n")
+	for _, item := range items {
+		self.WriteString("<li>" + html.EscapeString(item) + "</li>\n")
+	}
+	self.WriteString("</ul>\n")
+}
+
+func (self *htmlReference) Table(header []string, rows [][]referenceCell) {
+	self.WriteString("<table>\n<tr>")
+	for _, title := range header {
+		self.WriteString("<th>" + html.EscapeString(title) + "</th>")
+	}
+	self.WriteString("</tr>\n")
+	for _, row := range rows {
+		self.WriteString("<tr>")
+		for _, cell := range row {
+			self.WriteString("<td>")
+			if len(cell.Codes) > 0 {
+				for i, code := range cell.Codes {
+					if i > 0 { self.WriteString(", ") }
+					self.WriteString("<code>" + html.EscapeString(code) + "</code>")
+				}
+			} else {
+				self.WriteString(html.EscapeString(cell.Text))
+			}
+			self.WriteString("</td>")
+		}
+		self.WriteString("</tr>\n")
+	}
+	self.WriteString("</table>\n")
+}
@@ -0,0 +1,35 @@
+package badcli
+
+import "testing"
+import "strings"
+
+func newReferenceTestCLI() *CLI {
+	cli := NewCLI("tool", "Test tool for *reference* docs.")
+	cli.RegisterPersistentFlag("color" , "Color in hex or rgb format.", NewColorString(0, 0, 0), 'c')
+	cli.RegisterFlag("number", "Number between 11 and 99.", NewBoundedInt(0, 11, 99), 'n')
+	cli.RegisterFlag("verbose", "Verbose output.", NewSwitch(false), 'v')
+	cli.RegisterFlag("dry-run", "Don't write any files.", NewSwitch(false))
+	cli.SetFlagGroup("Debug", "verbose", "dry-run")
+	cli.RegisterExclusiveFlags("verbose", "dry-run")
+	cli.AddExample("-n 12 render in.png out.png", "Render with a <number>.")
+	cli.AddUsageSection("Colors can be given as '#RRGGBB' or 'rgb(R, G, B)'.")
+	render := cli.RegisterSubcommand("render", "Render an image.", "Renders an image.")
+	render.RegisterFlag("mode", "Blending mode | filter.", NewChoice("normal", "normal", "multiply"), 'm')
+	render.RegisterPositional("input", "Input file.", NewFilePath("", "png"))
+	render.RegisterOptionalPositional("output", "Output file.", NewFilePath("", "png"))
+	return cli
+}
+
+func TestMarkdownReference(t *testing.T) {
+	var doc strings.Builder
+	err := newReferenceTestCLI().WriteMarkdownReference(&doc)
+	if err != nil { t.Fatal(err) }
+	checkGoldenFile(t, "tool.md", doc.String())
+}
+
+func TestHTMLReference(t *testing.T) {
+	var doc strings.Builder
+	err := newReferenceTestCLI().WriteHTMLReference(&doc)
+	if err != nil { t.Fatal(err) }
+	checkGoldenFile(t, "tool.html", doc.String())
+}
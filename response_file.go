@@ -0,0 +1,140 @@
+package badcli
+
+import "os"
+import "errors"
+import "strings"
+import "path/filepath"
+import "unicode/utf8"
+
+// Enables response files: arguments like "@args.txt" are replaced by
+// the arguments contained in the given file before parsing. Response
+// files are tokenized like a basic shell would do it: arguments are
+// separated by whitespace, single quotes preserve everything literally,
+// double quotes and backslashes can be used to escape characters, and
+// lines starting with '#' are comments (a '#' anywhere else is kept, as
+// it's common in color values). Response files can reference other
+// response files.
+//
+// Expansion stops after "--". Values starting with '@' can still be
+// passed to flags using the "--flag=@value" syntax.
+func (self *CLI) EnableResponseFiles() {
+	self.responseFilesEnabled = true
+}
+
+// Replaces "@path" arguments with the contents of the files.
+func expandResponseFiles(args []string) ([]string, error) {
+	expanded := make([]string, 0, len(args))
+	flagsEnded := false
+	err := expandResponseFilesRec(args, &expanded, &flagsEnded, nil)
+	return expanded, err
+}
+
+func expandResponseFilesRec(args []string, expanded *[]string, flagsEnded *bool, stack []string) error {
+	for _, arg := range args {
+		if arg == "--" { *flagsEnded = true }
+		if *flagsEnded || !strings.HasPrefix(arg, "@") || arg == "@" {
+			*expanded = append(*expanded, arg)
+			continue
+		}
+
+		// cycle detection
+		path := arg[1 : ]
+		absPath, err := filepath.Abs(path)
+		if err != nil { absPath = filepath.Clean(path) }
+		for _, stackPath := range stack {
+			if stackPath == absPath {
+				return &ParseError{
+					Kind: ParseErrResponseFile, Arg: arg, File: path,
+					Err: errors.New("response file includes itself recursively"),
+				}
+			}
+		}
+
+		// read and tokenize file
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return &ParseError{ Kind: ParseErrResponseFile, Arg: arg, File: path, Err: err }
+		}
+		fileArgs, line, err := tokenizeResponseFile(string(data))
+		if err != nil {
+			return &ParseError{ Kind: ParseErrResponseFile, File: path, Line: line, Err: err }
+		}
+
+		// expand recursively
+		err = expandResponseFilesRec(fileArgs, expanded, flagsEnded, append(stack, absPath))
+		if err != nil { return err }
+	}
+	return nil
+}
+
+// Splits the response file contents into arguments. In case of
+// error, the line where the problem was found is also returned.
+func tokenizeResponseFile(contents string) ([]string, int, error) {
+	var args []string
+	var token strings.Builder
+	inToken := false // needed for empty quoted args like ""
+	lineStart := true // only whitespace found so far in the current line
+	line := 1
+	quoteLine := 0
+	for index := 0; index < len(contents); {
+		char, charLen := utf8.DecodeRuneInString(contents[index : ])
+		index += charLen
+		wasLineStart := lineStart
+		lineStart = (char == '\n' || (lineStart && (char == ' ' || char == '\t' || char == '\r')))
+		switch {
+		case char == '\n' || char == ' ' || char == '\t' || char == '\r':
+			if inToken {
+				args = append(args, token.String())
+				token.Reset()
+				inToken = false
+			}
+			if char == '\n' { line += 1 }
+		case char == '#' && wasLineStart: // comment
+			for index < len(contents) && contents[index] != '\n' { index += 1 }
+		case char == '\'':
+			inToken, quoteLine = true, line
+			end := strings.IndexByte(contents[index : ], '\'')
+			if end == -1 {
+				return nil, quoteLine, errors.New("unterminated single quote")
+			}
+			quoted := contents[index : index + end]
+			line += strings.Count(quoted, "\n")
+			token.WriteString(quoted)
+			index += end + 1
+		case char == '"':
+			inToken, quoteLine = true, line
+			closed := false
+			for index < len(contents) {
+				char, charLen = utf8.DecodeRuneInString(contents[index : ])
+				index += charLen
+				if char == '"' { closed = true ; break }
+				if char == '\n' { line += 1 }
+				if char == '\\' && index < len(contents) && (contents[index] == '"' || contents[index] == '\\') {
+					char = rune(contents[index])
+					index += 1
+				}
+				token.WriteRune(char)
+			}
+			if !closed {
+				return nil, quoteLine, errors.New("unterminated double quote")
+			}
+		case char == '\\':
+			if index >= len(contents) {
+				return nil, line, errors.New("unexpected backslash at end of file")
+			}
+			char, charLen = utf8.DecodeRuneInString(contents[index : ])
+			index += charLen
+			if char == '\n' { // line continuation
+				line += 1
+			} else {
+				inToken = true
+				token.WriteRune(char)
+			}
+		default:
+			inToken = true
+			token.WriteRune(char)
+		}
+	}
+	if inToken { args = append(args, token.String()) }
+	return args, 0, nil
+}
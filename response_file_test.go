@@ -0,0 +1,87 @@
+package badcli
+
+import "os"
+import "errors"
+import "testing"
+import "reflect"
+import "path/filepath"
+
+func TestTokenizeResponseFile(t *testing.T) {
+	tests := []struct{
+		in string
+		out []string
+	}{
+		{"", nil},
+		{"--color #fff\n--number 12\n", []string{"--color", "#fff", "--number", "12"}},
+		{"# comment\n-v #not-a-comment\n  # comment\n", []string{"-v", "#not-a-comment"}},
+		{"a#b 'c d' \"e \\\"f\\\"\"", []string{"a#b", "c d", "e \"f\""}},
+		{"'' \"\" x\\ y", []string{"", "", "x y"}},
+		{"multi\\\nline 'it''s'", []string{"multiline", "its"}},
+		{"--color '#fff' \\\n  --number 12", []string{"--color", "#fff", "--number", "12"}},
+		{"'rgb(1, 2, 3)'\t\"C:\\\\dir\"", []string{"rgb(1, 2, 3)", "C:\\dir"}},
+	}
+
+	for i, test := range tests {
+		args, _, err := tokenizeResponseFile(test.in)
+		if err != nil {
+			t.Fatalf("test#%d, tokenizeResponseFile(%q) returned an error: %s", i, test.in, err)
+		}
+		if !reflect.DeepEqual(args, test.out) {
+			t.Fatalf("test#%d, tokenizeResponseFile(%q) => %q (expected %q)", i, test.in, args, test.out)
+		}
+	}
+
+	errTests := []struct{
+		in string
+		line int
+	}{
+		{"a 'b\nc", 1},
+		{"a\nb \"c", 2},
+		{"a\n\nb\\", 3},
+	}
+	for i, test := range errTests {
+		_, line, err := tokenizeResponseFile(test.in)
+		if err == nil || line != test.line {
+			t.Fatalf("test#%d, tokenizeResponseFile(%q) => (line %d, '%v') (expected error at line %d)", i, test.in, line, err, test.line)
+		}
+	}
+}
+
+func TestResponseFiles(t *testing.T) {
+	dir := t.TempDir()
+	argsPath   := filepath.Join(dir, "args.txt")
+	colorsPath := filepath.Join(dir, "colors.txt")
+	loopPath   := filepath.Join(dir, "loop.txt")
+	files := map[string]string{
+		argsPath: "-v @" + colorsPath + "\n-- @literal\n",
+		colorsPath: "--color 'rgb(1, 2, 3)'\n",
+		loopPath: "-q @" + loopPath + "\n",
+	}
+	for path, contents := range files {
+		err := os.WriteFile(path, []byte(contents), 0644)
+		if err != nil { t.Fatal(err) }
+	}
+
+	cli := newTestCLI()
+	cli.EnableResponseFiles()
+	err := cli.ParseArgs([]string{"@" + argsPath, "x"})
+	if err != nil { t.Fatalf("unexpected error: %s", err) }
+	color := cli.GetFlagValue("color").(*ColorString).String()
+	if color != "rgb(1, 2, 3)" || !cli.FlagSetByUser("verbose") {
+		t.Fatalf("unexpected flag values")
+	}
+	if !reflect.DeepEqual(cli.ExtraArgs(), []string{"@literal", "x"}) {
+		t.Fatalf("unexpected extra args %q", cli.ExtraArgs())
+	}
+
+	err = cli.ParseArgs([]string{"@" + loopPath})
+	var parseErr *ParseError
+	if !errors.As(err, &parseErr) || parseErr.Kind != ParseErrResponseFile || parseErr.File != loopPath {
+		t.Fatalf("expected ParseErrResponseFile for recursive file, got '%v'", err)
+	}
+
+	err = cli.ParseArgs([]string{"@" + filepath.Join(dir, "missing.txt")})
+	if !errors.As(err, &parseErr) || parseErr.Kind != ParseErrResponseFile || !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected ParseErrResponseFile for missing file, got '%v'", err)
+	}
+}
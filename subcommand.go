@@ -0,0 +1,142 @@
+package badcli
+
+import "sort"
+import "strings"
+
+// Registers a subcommand, like "render" in "prog render --flags", and
+// returns it as a new [*CLI] whose flags and extra args can be configured
+// independently. The usage is a short description shown when listing
+// the commands of the parent, while the help description is shown when
+// using --help after the subcommand. Subcommands can be nested.
+//
+// When a CLI has subcommands, the first argument that's not a flag must
+// be the name of one of them, and all the arguments that follow it are
+// parsed by that subcommand. Use [CLI.SelectedSubcommand]() after parsing
+// to find out which one was used.
+func (self *CLI) RegisterSubcommand(name, usage, helpDescription string) *CLI {
+	// safety checks
+	if name == "" || name[0] == '-' || name == "/?" {
+		panic("invalid subcommand name ('" + name + "')")
+	}
+	if strings.ContainsAny(name, " \t\n") {
+		panic("subcommand name can't contain whitespace ('" + name + "')")
+	}
+	if len(self.positionals) > 0 {
+		panic("can't register subcommands on a CLI with positionals")
+	}
+	if _, alreadyRegistered := self.subcommands[name]; alreadyRegistered {
+		panic("subcommand already registered ('" + name + "')")
+	}
+
+	// actual registration
+	subcommand := NewCLI(self.programName + " " + name, helpDescription)
+	subcommand.parent = self
+	subcommand.subcommandUsage = usage
+	subcommand.stdout = self.stdout
+	subcommand.stderr = self.stderr
+	subcommand.exitFn = self.exitFn
+	subcommand.lookupEnvFn = self.lookupEnvFn
+	if self.subcommands == nil {
+		self.subcommands = make(map[string]*CLI)
+	}
+	self.subcommands[name] = subcommand
+	return subcommand
+}
+
+// Like [CLI.RegisterFlag](), but the flag will also be available to all
+// the subcommands, recursively, unless they register a flag or alias
+// with the same name themselves. Persistent flags can be placed before
+// or after the subcommand name, but can't be repeated.
+func (self *CLI) RegisterPersistentFlag(longFlagName, usage string, value FlagValue, aliases ...rune) {
+	self.RegisterFlag(longFlagName, usage, value, aliases...)
+	self.flags[longFlagName].Persistent = true
+}
+
+// Returns the subcommand selected during the last parsing, or nil if
+// none was used. Only the direct subcommand is returned; for nested
+// subcommands, call this method again on the result.
+func (self *CLI) SelectedSubcommand() *CLI {
+	return self.selectedSubcommand
+}
+
+// Returns the flag for the given long name, including persistent
+// flags inherited from parent commands.
+func (self *CLI) findFlag(longFlagName string) (*flag, bool) {
+	_, flagPtr := self.findFlagOwner(longFlagName)
+	return flagPtr, flagPtr != nil
+}
+
+// Like [CLI.findFlag](), but also returns the CLI where the flag was
+// registered. Both return values are nil if the flag is not found.
+func (self *CLI) findFlagOwner(longFlagName string) (*CLI, *flag) {
+	flagPtr, found := self.flags[longFlagName]
+	if found { return self, flagPtr }
+	for cli := self.parent; cli != nil; cli = cli.parent {
+		flagPtr, found = cli.flags[longFlagName]
+		if found && flagPtr.Persistent { return cli, flagPtr }
+	}
+	return nil, nil
+}
+
+// Returns the long flag name for the given alias, including aliases
+// of persistent flags inherited from parent commands. The returned
+// string will be empty if the alias doesn't exist.
+func (self *CLI) findAlias(alias rune) string {
+	longFlagName, found := self.flagShortAliases[alias]
+	if found { return longFlagName }
+	for cli := self.parent; cli != nil; cli = cli.parent {
+		longFlagName, found = cli.flagShortAliases[alias]
+		if found && cli.flags[longFlagName].Persistent {
+			return longFlagName
+		}
+	}
+	return ""
+}
+
+// Returns all the flag names available to the CLI, including
+// inherited persistent flags, sorted alphabetically.
+func (self *CLI) availableFlagNames() []string {
+	flagNames := make([]string, 0, len(self.flags))
+	for cli := self; cli != nil; cli = cli.parent {
+		for flagName, flagPtr := range cli.flags {
+			if cli != self && !flagPtr.Persistent { continue }
+			if cli != self {
+				if availablePtr, _ := self.findFlag(flagName); availablePtr != flagPtr {
+					continue // shadowed
+				}
+			}
+			flagNames = append(flagNames, flagName)
+		}
+	}
+	sort.Strings(flagNames)
+	return flagNames
+}
+
+// Returns the name of the subcommand in its parent, or an empty
+// string if the CLI is not a subcommand.
+func (self *CLI) subcommandName() string {
+	if self.parent == nil { return "" }
+	for name, subcommand := range self.parent.subcommands {
+		if subcommand == self { return name }
+	}
+	panic("subcommand not found in its parent")
+}
+
+// Returns the names of the subcommands, sorted alphabetically.
+func (self *CLI) subcommandNames() []string {
+	names := make([]string, 0, len(self.subcommands))
+	for name, _ := range self.subcommands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (self *CLI) subcommandUsageEntries() []usageEntry {
+	names := self.subcommandNames()
+	entries := make([]usageEntry, 0, len(names))
+	for _, name := range names {
+		entries = append(entries, usageEntry{ Left: name, Descr: self.subcommands[name].subcommandUsage })
+	}
+	return entries
+}
@@ -0,0 +1,43 @@
+package badcli
+
+import "errors"
+import "strconv"
+
+// Assert interface compliance.
+var _ SwitchFlagValue = (*Switch)(nil)
+
+// A boolean flag that doesn't take an argument. Using the flag
+// alone sets it to true, "--no-flag" sets it to false, and explicit
+// values like "--flag=false" are also accepted.
+type Switch struct {
+	value bool
+}
+
+func NewSwitch(value bool) *Switch {
+	return &Switch{ value: value }
+}
+
+func (self Switch) Value() bool {
+	return self.value
+}
+
+func (self Switch) String() string {
+	return strconv.FormatBool(self.value)
+}
+
+func (self *Switch) IsSwitch() bool { return true }
+
+func (self *Switch) ParseFromArg(arg string) error {
+	// no value case
+	if arg == "" {
+		self.value = true
+		return nil
+	}
+
+	value, err := strconv.ParseBool(arg)
+	if err != nil {
+		return errors.New("expected a boolean value like 'true' or 'false', but got '" + arg + "' instead")
+	}
+	self.value = value
+	return nil
+}
@@ -0,0 +1,65 @@
+package badcli
+
+import "io"
+import "fmt"
+import "strings"
+import "unicode/utf8"
+
+// A line for the argument listings of [CLI.PrintUsage](). The left
+// side is something like "--color, -c", and the right side is the
+// usage description.
+type usageEntry struct {
+	Left string
+	Descr string
+}
+
+// Prints the given entries in the same order they are given. Entries
+// that fit are aligned at a common breakpoint first, and entries that
+// don't are printed afterwards, with the description on separate lines.
+func printUsageEntries(output io.Writer, entries []usageEntry) {
+	// find usage description lengths
+	usageSplits := make([]split, len(entries))
+	for i, entry := range entries {
+		leftLen  := utf8.RuneCountInString(entry.Left)
+		descrLen := utf8.RuneCountInString(entry.Descr)
+		usageSplits[i] = split{ leftLen: uint16(leftLen), rightLen: uint16(descrLen) }
+	}
+
+	// TODO: I'm not considering the case of line breaks within Usage descriptions.
+	//       I don't know if I should check, but... I guess it's ok to ignore ftm.
+
+	tabSize := uint16(4) // approximate, we don't really know what the terminal does
+	leftVsDescrSpacing := uint16(4)
+	contentLen := 80 - tabSize - leftVsDescrSpacing
+	maxLeftLen := findBreakpointMin(usageSplits, contentLen)
+
+	// first iteration, print short lines
+	var strBuilder strings.Builder
+	for i, entry := range entries {
+		split := usageSplits[i]
+		spacesNeeded := int(maxLeftLen) - int(split.leftLen)
+		if spacesNeeded >= 0 && split.leftLen + split.rightLen <= contentLen {
+			strBuilder.Reset()
+			strBuilder.WriteByte('\t')
+			strBuilder.WriteString(entry.Left)
+			for i := spacesNeeded + int(leftVsDescrSpacing); i > 0; i-- {
+				strBuilder.WriteByte(' ')
+			}
+			strBuilder.WriteString(entry.Descr)
+			strBuilder.WriteByte('\n')
+			fmt.Fprint(output, strBuilder.String())
+		}
+	}
+
+	// second iteration, print long lines
+	for i, entry := range entries {
+		split := usageSplits[i]
+		if split.leftLen > maxLeftLen || split.leftLen + split.rightLen > contentLen {
+			fmt.Fprint(output, "\t", entry.Left, "\n")
+			EachLine(entry.Descr, 70, func(line string) error {
+				fmt.Fprint(output, "\t     ", line, "\n")
+				return nil
+			})
+		}
+	}
+}
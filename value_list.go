@@ -0,0 +1,149 @@
+package badcli
+
+import "fmt"
+import "errors"
+import "strconv"
+import "strings"
+
+// Assert interface compliance.
+var _ RepeatableFlagValue = (*ValueList[*ColorString])(nil)
+var _ ValidatedFlagValue = (*ValueList[*ColorString])(nil)
+var _ PlaceholderFlagValue = (*ValueList[*ColorString])(nil)
+var _ ResettableFlagValue = (*ValueList[*ColorString])(nil)
+var _ CompletableFlagValue = (*ValueList[*ColorString])(nil)
+
+// A flag value that can be given multiple times, like "--input a.png
+// --input b.png", collecting a value of the underlying type for each
+// occurrence. Values can also be split from a single argument if a
+// separator is set with [ValueList.SetSeparator]().
+type ValueList[V FlagValue] struct {
+	values []V
+	defaults []V
+	newValue func() V
+	separator string
+	minCount int
+	maxCount int // negative for no limit
+	parsed bool // set after the first parse, so defaults can be replaced
+}
+
+// Creates a list that uses newValue to create each element before
+// parsing it. The default values, if any, are replaced as soon as
+// the user sets any value.
+func NewValueList[V FlagValue](newValue func() V, defaults ...V) *ValueList[V] {
+	return &ValueList[V]{
+		values: defaults,
+		defaults: defaults,
+		newValue: newValue,
+		maxCount: -1,
+	}
+}
+
+// Creates a list of colors. See [ColorString].
+func NewColorStringList(defaults ...*ColorString) *ValueList[*ColorString] {
+	return NewValueList(func() *ColorString { return NewColorString(0, 0, 0) }, defaults...)
+}
+
+// Creates a list of file paths. See [FilePath].
+func NewFilePathList(allowedExtensions ...string) *ValueList[*FilePath] {
+	return NewValueList(func() *FilePath { return NewFilePath("", allowedExtensions...) })
+}
+
+// Creates a list of integers within [min, max]. See [BoundedInt].
+func NewBoundedIntList(min, max int, defaults ...int) *ValueList[*BoundedInt] {
+	values := make([]*BoundedInt, len(defaults))
+	for i, value := range defaults {
+		values[i] = NewBoundedInt(value, min, max)
+	}
+	return NewValueList(func() *BoundedInt { return NewBoundedInt(0, min, max) }, values...)
+}
+
+// Sets a separator to allow multiple values in a single argument,
+// like "--sizes 8,16,32". Empty separators disable splitting (the
+// default). Notice that some values, like "rgb(1, 2, 3)", may contain
+// common separators. Returns the list itself for chaining.
+func (self *ValueList[V]) SetSeparator(separator string) *ValueList[V] {
+	self.separator = separator
+	return self
+}
+
+// Sets the minimum and maximum number of values. Use a negative max
+// for no limit. Returns the list itself for chaining.
+func (self *ValueList[V]) SetCountRange(min, max int) *ValueList[V] {
+	if min < 0 || (max >= 0 && max < min) {
+		panic("invalid value count range")
+	}
+	self.minCount = min
+	self.maxCount = max
+	return self
+}
+
+func (self *ValueList[V]) Values() []V {
+	return self.values
+}
+
+func (self *ValueList[V]) Len() int {
+	return len(self.values)
+}
+
+func (self *ValueList[V]) String() string {
+	var strBuilder strings.Builder
+	for i, value := range self.values {
+		if i > 0 { strBuilder.WriteString(", ") }
+		fmt.Fprint(&strBuilder, value)
+	}
+	return strBuilder.String()
+}
+
+// Returns the placeholder of the underlying value type.
+func (self *ValueList[V]) Placeholder() string {
+	return placeholderOf(self.newValue())
+}
+
+// Returns the completion candidates of the underlying value type,
+// if any.
+func (self *ValueList[V]) CompletionCandidates() CompletionCandidates {
+	completable, ok := any(self.newValue()).(CompletableFlagValue)
+	if !ok { return CompletionCandidates{} }
+	return completable.CompletionCandidates()
+}
+
+func (self *ValueList[V]) IsRepeatable() bool { return true }
+
+// Restores the default values, so values from previous parsings
+// don't accumulate.
+func (self *ValueList[V]) Reset() {
+	self.values = self.defaults
+	self.parsed = false
+}
+
+func (self *ValueList[V]) ParseFromArg(arg string) error {
+	if !self.parsed {
+		self.values = nil
+		self.parsed = true
+	}
+
+	parts := []string{ arg }
+	if self.separator != "" {
+		parts = strings.Split(arg, self.separator)
+	}
+	for _, part := range parts {
+		if self.maxCount >= 0 && len(self.values) >= self.maxCount {
+			return errors.New("too many values (maximum is " + strconv.Itoa(self.maxCount) + ")")
+		}
+		value := self.newValue()
+		err := value.ParseFromArg(part)
+		if err != nil { return err }
+		self.values = append(self.values, value)
+	}
+	return nil
+}
+
+func (self *ValueList[V]) Validate() error {
+	if len(self.values) < self.minCount {
+		noun := " values"
+		if self.minCount == 1 { noun = " value" }
+		return errors.New("expected at least " + strconv.Itoa(self.minCount) +
+			noun + ", but got " + strconv.Itoa(len(self.values)))
+	}
+	return nil
+}
@@ -0,0 +1,37 @@
+package badcli
+
+import "testing"
+
+func TestValueList(t *testing.T) {
+	tests := []struct{
+		list *ValueList[*BoundedInt]
+		in []string
+		out string
+		fail bool
+	}{
+		{NewBoundedIntList(0, 9, 1, 2), nil, "1, 2", false},
+		{NewBoundedIntList(0, 9, 1, 2), []string{"3"}, "3", false},
+		{NewBoundedIntList(0, 9), []string{"3", "4", "5"}, "3, 4, 5", false},
+		{NewBoundedIntList(0, 9).SetSeparator(","), []string{"3,4", "5"}, "3, 4, 5", false},
+		{NewBoundedIntList(0, 9), []string{"3,4"}, "", true},
+		{NewBoundedIntList(0, 9), []string{"10"}, "", true},
+		{NewBoundedIntList(0, 9).SetCountRange(0, 2), []string{"1", "2", "3"}, "", true},
+		{NewBoundedIntList(0, 9).SetCountRange(2, -1), []string{"1"}, "", true},
+		{NewBoundedIntList(0, 9).SetCountRange(2, -1), []string{"1", "2"}, "1, 2", false},
+	}
+
+	for i, test := range tests {
+		var err error
+		for _, arg := range test.in {
+			err = test.list.ParseFromArg(arg)
+			if err != nil { break }
+		}
+		if err == nil { err = test.list.Validate() }
+		if (err != nil) != test.fail {
+			t.Fatalf("test#%d, parsing %q returned error '%v' (expected failure: %t)", i, test.in, err, test.fail)
+		}
+		if !test.fail && test.list.String() != test.out {
+			t.Fatalf("test#%d, parsing %q => \"%s\" (expected \"%s\")", i, test.in, test.list.String(), test.out)
+		}
+	}
+}